@@ -5,7 +5,11 @@ import (
 	"os"
 	"start-my-game/lib/cloud"
 	"start-my-game/lib/config"
+	"start-my-game/lib/game"
 	"start-my-game/lib/manager"
+	"start-my-game/lib/notify"
+	"start-my-game/lib/schedule"
+	"start-my-game/lib/state"
 	"start-my-game/lib/web"
 )
 
@@ -35,10 +39,38 @@ func main() {
 
 	log.Printf("Initalized cloud with provider %v\n", acloud.GetProvider())
 
-	newManager := manager.NewManager(cfg, acloud)
+	// Create game adapter
+	adapter, err := game.NewAdapter(cfg)
+	if err != nil {
+		log.Panicln("Couldn't init game:", err)
+	}
+
+	log.Printf("Initalized game adapter for %v\n", adapter.GetType())
+
+	// Read the state of the last run
+	store, err := state.Open()
+	if err != nil {
+		log.Panicln("Couldn't open state:", err)
+	}
+
+	// Create notifiers
+	dispatcher, err := notify.NewDispatcher(cfg, acloud, adapter)
+	if err != nil {
+		log.Panicln("Couldn't init notifications:", err)
+	}
+
+	// Read the scheduled starts and quiet hours
+	sched, err := schedule.New(cfg)
+	if err != nil {
+		log.Panicln("Couldn't read schedule:", err)
+	}
+
+	newManager := manager.NewManager(cfg, acloud, adapter, store, sched)
+	go dispatcher.Run(newManager)
 	// go newManager.DelayCheckStart()
 	go newManager.StartCheck()
+	go newManager.StartSchedule()
 
 	// TODO: Run with go
-	web.Start(cfg, newManager)
+	web.Start(cfg, newManager, adapter)
 }
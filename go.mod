@@ -7,7 +7,6 @@ require (
 	github.com/digitalocean/godo v1.32.0
 	github.com/google/go-querystring v1.0.0 // indirect
 	github.com/hetznercloud/hcloud-go v1.17.0
-	github.com/james4k/rcon v0.0.0-20120923215419-8fbb8268b60a
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rs/cors v1.7.0
 	github.com/tent/http-link-go v0.0.0-20130702225549-ac974c61c2f9 // indirect
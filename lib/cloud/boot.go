@@ -0,0 +1,21 @@
+package cloud
+
+import "time"
+
+// The time a new or stopped server usually needs to become active
+const DefaultBootTimeout = 5 * time.Minute
+
+// Implemented by clouds, which need more time than DefaultBootTimeout to boot a server
+type BootTimeoutProvider interface {
+	BootTimeout() time.Duration
+}
+
+// Returns DefaultBootTimeout if the cloud doesn't specify its own timeout
+func BootTimeout(cloud Cloud) time.Duration {
+	provider, ok := cloud.(BootTimeoutProvider)
+	if !ok {
+		return DefaultBootTimeout
+	}
+
+	return provider.BootTimeout()
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"start-my-game/lib/config"
 	"strings"
+	"time"
 )
 
 const (
@@ -13,15 +14,27 @@ const (
 	StatusDestroyed = "destroyed"
 )
 
+// Maximum duration to wait for a snapshot to be taken
+const snapshotTimeout = 60 * time.Minute
+
+// Interval of the progress checks of providers without a blocking snapshot action, tests shorten it
+var snapshotPollInterval = 10 * time.Second
+
 type Cloud interface {
 	GetProvider() string
 	GetSSHKey(fingerprint string) (int, error)
+	// Returns the newest snapshot with the name
 	GetSnapshot(name string) (*Snapshot, error)
+	// Returns all snapshots with the name including the ones taken on shutdown
+	ListSnapshots(name string) ([]*Snapshot, error)
+	DeleteSnapshot(snapshot *Snapshot) error
 	GetServer(name string) (*Server, error)
 	StartServer(server *Server) error
 	StopServer(server *Server) error
 	CreateServer(options CreateOptions) (*Server, error)
 	DestroyServer(server *Server) error
+	// Takes a snapshot of the server and waits until it's available
+	CreateSnapshot(server *Server, name string) (*Snapshot, error)
 }
 
 type Server struct {
@@ -33,8 +46,9 @@ type Server struct {
 }
 
 type Snapshot struct {
-	Name string
-	Id   int
+	Name    string
+	Id      int
+	Created time.Time
 }
 
 type CreateOptions struct {
@@ -45,6 +59,31 @@ type CreateOptions struct {
 	Region   string
 }
 
+// The name of a snapshot taken on shutdown, GetSnapshot returns the newest of them
+func SnapshotName(name string, created time.Time) string {
+	return fmt.Sprintf("%v %v", name, created.UTC().Format("2006-01-02 15:04"))
+}
+
+// Whether the snapshot has the name or is named like a snapshot taken on shutdown
+func matchesSnapshot(snapshotName string, name string) bool {
+	lowerSnapshot := strings.ToLower(snapshotName)
+	lowerName := strings.ToLower(name)
+
+	return lowerSnapshot == lowerName || strings.HasPrefix(lowerSnapshot, lowerName+" ")
+}
+
+func newestSnapshot(snapshots []*Snapshot) *Snapshot {
+	var newest *Snapshot
+
+	for _, snapshot := range snapshots {
+		if newest == nil || snapshot.Created.After(newest.Created) {
+			newest = snapshot
+		}
+	}
+
+	return newest
+}
+
 func NewCloud(config *config.Config) (Cloud, error) {
 	provider := strings.ToLower(config.Cloud.Provider)
 	token := config.Cloud.Token
@@ -56,11 +95,35 @@ func NewCloud(config *config.Config) (Cloud, error) {
 		cloud = newHCloud(token)
 	case strings.ToLower(digitalOceanProvider):
 		cloud = newDoCloud(token)
+	case strings.ToLower(vultrProvider):
+		cloud = newVultrCloud(config)
+	case strings.ToLower(linodeProvider):
+		cloud = newLinodeCloud(config)
+	case strings.ToLower(dockerProvider):
+		docker, err := newDockerCloud(config)
+		if err != nil {
+			return nil, err
+		}
+		cloud = docker
+	case strings.ToLower(libvirtProvider):
+		libvirt, err := newLibvirtCloud(config)
+		if err != nil {
+			return nil, err
+		}
+		cloud = libvirt
+	case strings.ToLower(physicalProvider):
+		physical, err := newPhysicalCloud(config)
+		if err != nil {
+			return nil, err
+		}
+		cloud = physical
+	case strings.ToLower(fakeProvider):
+		cloud = newFakeCloud(config)
 	}
 
 	if cloud != nil {
 		// No reference, because the cloud is an interface
-		return cloud, nil
+		return instrument(cloud), nil
 	}
 
 	return nil, fmt.Errorf("could provider with name '%v' not found", provider)
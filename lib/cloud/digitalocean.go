@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"github.com/digitalocean/godo"
 	"golang.org/x/oauth2"
+	"log"
 	"strings"
+	"time"
 )
 
 // https://developers.digitalocean.com/documentation/v2/
@@ -41,31 +43,71 @@ func (cloud *DoCloud) GetSSHKey(fingerprint string) (int, error) {
 }
 
 func (cloud *DoCloud) GetSnapshot(name string) (*Snapshot, error) {
-	listOptions := godo.ListOptions{
-		Page:    1,
-		PerPage: 200,
+	images, err := cloud.listUserImages(cloud.context)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list images: %v", err)
 	}
 
-	images, _, err := cloud.client.Images.ListUser(cloud.context, &listOptions)
+	snapshots := filterDoSnapshots(images, name)
+	if len(snapshots) == 0 {
+		// Earlier versions matched every snapshot containing the name
+		lowerName := strings.ToLower(strings.TrimRight(name, " "))
+		for _, image := range images {
+			if strings.Contains(strings.ToLower(image.Name), lowerName) {
+				snapshots = append(snapshots, toDoSnapshot(&image))
+			}
+		}
+
+		if len(snapshots) > 0 {
+			log.Printf("No snapshot is named '%v' or starts with '%v ', using the newest one containing "+
+				"the name. Please rename the snapshot, this fallback will be removed.\n", name, name)
+		}
+	}
+
+	if len(snapshots) == 0 {
+		return nil, newNotExistsError("snapshot", name, nil)
+	}
+
+	return newestSnapshot(snapshots), nil
+}
+
+func (cloud *DoCloud) ListSnapshots(name string) ([]*Snapshot, error) {
+	images, err := cloud.listUserImages(cloud.context)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't list images: %v", err)
 	}
 
-	lowerName := strings.ToLower(strings.TrimRight(name, " "))
+	return filterDoSnapshots(images, name), nil
+}
 
-	for _, image := range images {
+func (cloud *DoCloud) DeleteSnapshot(snapshot *Snapshot) error {
+	_, err := cloud.client.Images.Delete(cloud.context, snapshot.Id)
+	if err != nil {
+		return fmt.Errorf("couldn't delete snapshot: %v", err)
+	}
 
-		if strings.Index(strings.ToLower(image.Name), lowerName) < 0 {
-			continue
-		}
+	return nil
+}
 
-		return &Snapshot{
-			Name: image.Name,
-			Id:   image.ID,
-		}, nil
+// The prices of the sizes are the same in all regions
+func (cloud *DoCloud) HourlyPrice(serverType string, region string) (float64, error) {
+	listOptions := godo.ListOptions{
+		Page:    1,
+		PerPage: 200,
 	}
 
-	return nil, newNotExistsError("snapshot", name, nil)
+	sizes, _, err := cloud.client.Sizes.List(cloud.context, &listOptions)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't list sizes: %v", err)
+	}
+
+	for _, size := range sizes {
+		if strings.EqualFold(size.Slug, serverType) {
+			return size.PriceHourly, nil
+		}
+	}
+
+	return 0, newNotExistsError("size", serverType, nil)
 }
 
 func (cloud *DoCloud) GetServer(name string) (*Server, error) {
@@ -157,6 +199,97 @@ func (cloud *DoCloud) DestroyServer(server *Server) error {
 	return nil
 }
 
+func (cloud *DoCloud) CreateSnapshot(server *Server, name string) (*Snapshot, error) {
+	ctx, cancel := context.WithTimeout(cloud.context, snapshotTimeout)
+	defer cancel()
+
+	action, _, err := cloud.client.DropletActions.Snapshot(ctx, server.Id, name)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create snapshot: %v", err)
+	}
+
+	// Polling the action until it's finished
+	for action.Status == godo.ActionInProgress {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("couldn't finish snapshot of droplet %v: %v", server.Name, ctx.Err())
+		case <-time.After(10 * time.Second):
+		}
+
+		action, _, err = cloud.client.DropletActions.Get(ctx, server.Id, action.ID)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get the snapshot progress: %v", err)
+		}
+	}
+
+	if action.Status != godo.ActionCompleted {
+		return nil, fmt.Errorf("snapshot with status '%v' for droplet %v", action.Status, server.Name)
+	}
+
+	images, err := cloud.listUserImages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list images: %v", err)
+	}
+
+	for _, image := range images {
+		if image.Name == name {
+			return toDoSnapshot(&image), nil
+		}
+	}
+
+	return nil, newNotExistsError("snapshot", name, nil)
+}
+
+// Reads the images of the user from all pages
+func (cloud *DoCloud) listUserImages(ctx context.Context) ([]godo.Image, error) {
+	listOptions := godo.ListOptions{
+		Page:    1,
+		PerPage: 200,
+	}
+
+	var images []godo.Image
+	for {
+		page, response, err := cloud.client.Images.ListUser(ctx, &listOptions)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, page...)
+
+		if response.Links == nil || response.Links.IsLastPage() {
+			return images, nil
+		}
+
+		current, err := response.Links.CurrentPage()
+		if err != nil {
+			return nil, err
+		}
+		listOptions.Page = current + 1
+	}
+}
+
+func filterDoSnapshots(images []godo.Image, name string) []*Snapshot {
+	var snapshots []*Snapshot
+
+	for _, image := range images {
+		if matchesSnapshot(image.Name, strings.TrimRight(name, " ")) {
+			snapshots = append(snapshots, toDoSnapshot(&image))
+		}
+	}
+
+	return snapshots
+}
+
+func toDoSnapshot(image *godo.Image) *Snapshot {
+	// Snapshots without a valid creation date are treated as the oldest ones
+	created, _ := time.Parse(time.RFC3339, image.Created)
+
+	return &Snapshot{
+		Name:    image.Name,
+		Id:      image.ID,
+		Created: created,
+	}
+}
+
 func (cloud *DoCloud) dropletToServer(droplet *godo.Droplet, ipv4 string) (*Server, error) {
 	status := StatusOff
 
@@ -0,0 +1,68 @@
+package cloud
+
+import (
+	"net/url"
+	"testing"
+)
+
+func newTestDoCloud(t *testing.T, responses map[string][]string) *DoCloud {
+	_, endpoint := newRecordedApi(t, responses)
+
+	cloud := newDoCloud("token")
+	cloud.client.BaseURL, _ = url.Parse(endpoint + "/")
+
+	return cloud
+}
+
+const doImagesKey = "GET /v2/images?page=1&per_page=200&private=true"
+
+func TestDoGetSnapshot(t *testing.T) {
+	cloud := newTestDoCloud(t, map[string][]string{
+		doImagesKey: {`{"images": [
+			{"id": 1, "name": "gmod", "created_at": "2020-11-01T10:00:00Z"},
+			{"id": 2, "name": "gmod 2020-11-20 18:30", "created_at": "2020-11-20T18:30:00Z"},
+			{"id": 3, "name": "old gmod backup", "created_at": "2020-12-01T10:00:00Z"},
+			{"id": 4, "name": "gmodx", "created_at": "2020-12-02T10:00:00Z"}
+		], "links": {}}`},
+	})
+
+	snapshot, err := cloud.GetSnapshot("gmod")
+	if err != nil {
+		t.Fatalf("GetSnapshot failed: %v", err)
+	}
+	if snapshot.Id != 2 {
+		t.Errorf("got snapshot %+v, expected the newest one named exactly or starting with the name", snapshot)
+	}
+
+	snapshots, err := cloud.ListSnapshots("gmod")
+	if err != nil || len(snapshots) != 2 {
+		t.Errorf("got snapshots %v (%v), expected only the exact and the prefix match", snapshots, err)
+	}
+}
+
+func TestDoGetSnapshotFallback(t *testing.T) {
+	cloud := newTestDoCloud(t, map[string][]string{
+		doImagesKey: {`{"images": [
+			{"id": 1, "name": "My Gmod Server", "created_at": "2020-11-01T10:00:00Z"},
+			{"id": 2, "name": "minecraft", "created_at": "2020-12-01T10:00:00Z"}
+		], "links": {}}`},
+	})
+
+	// Snapshots only containing the name are still found like before
+	snapshot, err := cloud.GetSnapshot("gmod")
+	if err != nil {
+		t.Fatalf("GetSnapshot failed: %v", err)
+	}
+	if snapshot.Id != 1 {
+		t.Errorf("got snapshot %+v, expected the one containing the name", snapshot)
+	}
+
+	// The retention never deletes snapshots matched by the fallback
+	if snapshots, _ := cloud.ListSnapshots("gmod"); len(snapshots) != 0 {
+		t.Errorf("got snapshots %v, expected none", snapshots)
+	}
+
+	if _, err := cloud.GetSnapshot("rust"); !IsNotExistsError(err) {
+		t.Errorf("expected a not exists error, got %v", err)
+	}
+}
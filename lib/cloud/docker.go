@@ -0,0 +1,388 @@
+package cloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"start-my-game/lib/config"
+	"strings"
+	"time"
+)
+
+// Runs the game server as a container of the local Docker Engine, the snapshot is an image
+// https://docs.docker.com/engine/api/
+const dockerProvider string = "Docker"
+
+const dockerEndpoint = "unix:///var/run/docker.sock"
+
+// Stores the snapshot name of committed images, because it isn't a valid tag
+const dockerSnapshotLabel = "start-my-game.snapshot"
+
+// Seconds to wait for the container to stop before it's killed
+const dockerStopTimeout = 20
+
+type DockerCloud struct {
+	rest *restClient
+	// The address of the published ports, empty if the container IP is used
+	host string
+	// Docker uses hex strings as ids
+	ids *idMap
+}
+
+type dockerImage struct {
+	Id       string            `json:"Id"`
+	RepoTags []string          `json:"RepoTags"`
+	Created  int64             `json:"Created"`
+	Labels   map[string]string `json:"Labels"`
+}
+
+type dockerContainer struct {
+	Id     string   `json:"Id"`
+	Names  []string `json:"Names"`
+	State  string   `json:"State"`
+	Status string   `json:"Status"`
+	Ports  []struct {
+		PublicPort int `json:"PublicPort"`
+	} `json:"Ports"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+type dockerPortBinding struct {
+	HostPort string `json:"HostPort"`
+}
+
+type dockerCreateContainer struct {
+	Image        string              `json:"Image"`
+	Hostname     string              `json:"Hostname"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+	HostConfig   struct {
+		PortBindings map[string][]dockerPortBinding `json:"PortBindings"`
+	} `json:"HostConfig"`
+}
+
+func (cloud *DockerCloud) GetProvider() string {
+	return dockerProvider
+}
+
+// Containers don't need a ssh key
+func (cloud *DockerCloud) GetSSHKey(fingerprint string) (int, error) {
+	return 0, nil
+}
+
+func (cloud *DockerCloud) GetSnapshot(name string) (*Snapshot, error) {
+	snapshots, err := cloud.ListSnapshots(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) == 0 {
+		return nil, newNotExistsError("snapshot", name, nil)
+	}
+
+	return newestSnapshot(snapshots), nil
+}
+
+// The name is an image reference like 'gmod' or 'registry/gmod:tag', the images committed on
+// shutdown are found by their label
+func (cloud *DockerCloud) ListSnapshots(name string) ([]*Snapshot, error) {
+	var images []dockerImage
+
+	err := cloud.rest.do("GET", "/images/json", nil, &images)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list images: %v", err)
+	}
+
+	var snapshots []*Snapshot
+
+	for _, image := range images {
+		snapshotName := ""
+
+		if label := image.Labels[dockerSnapshotLabel]; label != "" && matchesSnapshot(label, name) {
+			snapshotName = label
+		} else {
+			for _, tag := range image.RepoTags {
+				if sameImage(tag, name) {
+					snapshotName = name
+					break
+				}
+			}
+		}
+
+		if snapshotName == "" {
+			continue
+		}
+
+		snapshots = append(snapshots, &Snapshot{
+			Name:    snapshotName,
+			Id:      cloud.ids.add(image.Id),
+			Created: time.Unix(image.Created, 0),
+		})
+	}
+
+	return snapshots, nil
+}
+
+func (cloud *DockerCloud) DeleteSnapshot(snapshot *Snapshot) error {
+	id, ok := cloud.ids.get(snapshot.Id)
+	if !ok {
+		return newNotExistsError("snapshot", snapshot.Name, nil)
+	}
+
+	err := cloud.rest.do("DELETE", "/images/"+id, nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't delete image: %v", err)
+	}
+
+	return nil
+}
+
+func (cloud *DockerCloud) GetServer(name string) (*Server, error) {
+	filters, _ := json.Marshal(map[string][]string{"name": {"^/" + name + "$"}})
+
+	var containers []dockerContainer
+	err := cloud.rest.do("GET", "/containers/json?all=true&filters="+url.QueryEscape(string(filters)), nil, &containers)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list containers: %v", err)
+	}
+
+	for _, container := range containers {
+		for _, containerName := range container.Names {
+			if strings.TrimPrefix(containerName, "/") == name {
+				return cloud.toDockerServer(name, &container), nil
+			}
+		}
+	}
+
+	return nil, newNotExistsError("server", name, nil)
+}
+
+func (cloud *DockerCloud) StartServer(server *Server) error {
+	err := cloud.rest.do("POST", "/containers/"+url.PathEscape(server.Name)+"/start", nil, nil)
+	// Docker responds with 304 if the container is already running
+	if err != nil && !isRestStatus(err, 304) {
+		return fmt.Errorf("couldn't start container: %v", err)
+	}
+
+	return nil
+}
+
+func (cloud *DockerCloud) StopServer(server *Server) error {
+	path := fmt.Sprintf("/containers/%v/stop?t=%v", url.PathEscape(server.Name), dockerStopTimeout)
+
+	err := cloud.rest.do("POST", path, nil, nil)
+	if err != nil && !isRestStatus(err, 304) {
+		return fmt.Errorf("couldn't stop container: %v", err)
+	}
+
+	return nil
+}
+
+// Publishes the ports exposed by the image on the same ports of the host. The machine type and
+// region aren't used.
+func (cloud *DockerCloud) CreateServer(options CreateOptions) (*Server, error) {
+	imageId, ok := cloud.ids.get(options.Snapshot.Id)
+	if !ok {
+		return nil, newNotExistsError("snapshot", options.Snapshot.Name, nil)
+	}
+
+	var image struct {
+		Config struct {
+			ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+		} `json:"Config"`
+	}
+
+	err := cloud.rest.do("GET", "/images/"+imageId+"/json", nil, &image)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't inspect image: %v", err)
+	}
+
+	request := dockerCreateContainer{
+		Image:        imageId,
+		Hostname:     options.Name,
+		ExposedPorts: image.Config.ExposedPorts,
+	}
+	request.HostConfig.PortBindings = make(map[string][]dockerPortBinding)
+	for port := range image.Config.ExposedPorts {
+		// The ports look like '27015/udp'
+		hostPort := strings.SplitN(port, "/", 2)[0]
+		request.HostConfig.PortBindings[port] = []dockerPortBinding{{HostPort: hostPort}}
+	}
+
+	var created struct {
+		Id string `json:"Id"`
+	}
+
+	err = cloud.rest.do("POST", "/containers/create?name="+url.QueryEscape(options.Name), request, &created)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create container: %v", err)
+	}
+
+	err = cloud.rest.do("POST", "/containers/"+created.Id+"/start", nil, nil)
+	if err != nil {
+		// The container would block the name for the next attempt, e.g. if a port is already in use
+		removeErr := cloud.rest.do("DELETE", "/containers/"+created.Id+"?force=true", nil, nil)
+		if removeErr != nil {
+			return nil, fmt.Errorf("couldn't start container: %v (couldn't remove it: %v)", err, removeErr)
+		}
+		return nil, fmt.Errorf("couldn't start container: %v", err)
+	}
+
+	return cloud.GetServer(options.Name)
+}
+
+func (cloud *DockerCloud) DestroyServer(server *Server) error {
+	err := cloud.rest.do("DELETE", "/containers/"+url.PathEscape(server.Name)+"?force=true", nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't remove the container: %v", err)
+	}
+
+	return nil
+}
+
+// Commits the container as an image, which is tagged with the time of the snapshot name
+func (cloud *DockerCloud) CreateSnapshot(server *Server, name string) (*Snapshot, error) {
+	repository, tag := snapshotImageTag(name)
+
+	query := url.Values{}
+	query.Set("container", server.Name)
+	query.Set("repo", repository)
+	query.Set("tag", tag)
+
+	var committed struct {
+		Id string `json:"Id"`
+	}
+
+	err := cloud.rest.do("POST", "/commit?"+query.Encode(), map[string]interface{}{
+		"Labels": map[string]string{dockerSnapshotLabel: name},
+	}, &committed)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't commit container: %v", err)
+	}
+
+	return &Snapshot{
+		Name:    name,
+		Id:      cloud.ids.add(committed.Id),
+		Created: time.Now(),
+	}, nil
+}
+
+func (cloud *DockerCloud) toDockerServer(name string, container *dockerContainer) *Server {
+	status := StatusOff
+
+	switch container.State {
+	case "created", "restarting":
+		status = StatusStartup
+	case "running":
+		// Images with a health check aren't ready until it passed
+		if strings.Contains(container.Status, "health: starting") {
+			status = StatusStartup
+		} else {
+			status = StatusActive
+		}
+	}
+
+	ip := ""
+	if cloud.host != "" && len(container.Ports) > 0 {
+		ip = cloud.host
+	} else {
+		for _, network := range container.NetworkSettings.Networks {
+			if network.IPAddress != "" {
+				ip = network.IPAddress
+				break
+			}
+		}
+	}
+
+	return &Server{
+		Name:     name,
+		Id:       cloud.ids.add(container.Id),
+		Ip:       ip,
+		Status:   status,
+		Provider: dockerProvider,
+	}
+}
+
+// Splits an image reference into the repository and the tag, which is empty if it's missing
+func splitImageTag(reference string) (string, string) {
+	colon := strings.LastIndex(reference, ":")
+	// The colon could also belong to the port of a registry
+	if colon < 0 || strings.Contains(reference[colon:], "/") {
+		return reference, ""
+	}
+
+	return reference[:colon], reference[colon+1:]
+}
+
+// Whether both references point to the same image, a missing tag is 'latest'
+func sameImage(reference string, other string) bool {
+	repository, tag := splitImageTag(reference)
+	otherRepository, otherTag := splitImageTag(other)
+
+	if tag == "" {
+		tag = "latest"
+	}
+	if otherTag == "" {
+		otherTag = "latest"
+	}
+
+	return repository == otherRepository && tag == otherTag
+}
+
+// Converts a name like 'gmod 2020-01-02 15:04' into the repository 'gmod' and the tag '2020-01-02-15-04'
+func snapshotImageTag(name string) (string, string) {
+	reference, suffix := name, ""
+	if space := strings.Index(name, " "); space >= 0 {
+		reference, suffix = name[:space], name[space+1:]
+	}
+
+	repository, tag := splitImageTag(reference)
+	if suffix == "" {
+		return repository, tag
+	}
+
+	// Tags may only contain letters, digits, underscores, periods and dashes
+	tag = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, suffix)
+
+	return repository, tag
+}
+
+func newDockerCloud(cfg *config.Config) (*DockerCloud, error) {
+	endpoint := cfg.Cloud.Endpoint
+	if endpoint == "" {
+		endpoint = dockerEndpoint
+	}
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker endpoint '%v': %v", endpoint, err)
+	}
+
+	cloud := &DockerCloud{ids: newIdMap()}
+
+	switch parsed.Scheme {
+	case "unix":
+		cloud.rest = newUnixRestClient("http://docker", parsed.Path)
+		cloud.host = "127.0.0.1"
+	case "tcp", "http":
+		cloud.rest = newRestClient("http://"+parsed.Host, "")
+		cloud.host = parsed.Hostname()
+	default:
+		return nil, fmt.Errorf("invalid docker endpoint '%v': use unix:// or tcp://", endpoint)
+	}
+
+	// Committing a large container takes a while
+	cloud.rest.client.Timeout = 10 * time.Minute
+
+	return cloud, nil
+}
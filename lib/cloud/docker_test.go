@@ -0,0 +1,127 @@
+package cloud
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"start-my-game/lib/config"
+	"strings"
+	"testing"
+)
+
+const testContainerId = "4fa6e0f0c6786287e131c3852c58a2e01cc697a68231826813597e4994f1d6e2"
+
+func newTestDockerCloud(t *testing.T, responses map[string][]string) (*DockerCloud, *recordedApi) {
+	api, endpoint := newRecordedApi(t, responses)
+
+	cfg := &config.Config{}
+	cfg.Cloud.Endpoint = "tcp://" + strings.TrimPrefix(endpoint, "http://")
+
+	cloud, err := newDockerCloud(cfg)
+	if err != nil {
+		t.Fatalf("couldn't create the cloud: %v", err)
+	}
+
+	return cloud, api
+}
+
+// The request listing the containers with the name
+func containersKey(name string) string {
+	filters, _ := json.Marshal(map[string][]string{"name": {"^/" + name + "$"}})
+	return "GET /containers/json?all=true&filters=" + url.QueryEscape(string(filters))
+}
+
+// The responses for finding the snapshot and creating a container, a new map for every test
+func dockerCreateResponses() map[string][]string {
+	return map[string][]string{
+		"GET /images/json": {`[
+			{"Id": "sha256:1111", "RepoTags": ["gmod:latest"], "Created": 1600000000},
+			{"Id": "sha256:2222", "RepoTags": ["gmod:2020-11-20-18-30"], "Created": 1605897000,
+				"Labels": {"start-my-game.snapshot": "gmod 2020-11-20 18:30"}},
+			{"Id": "sha256:3333", "RepoTags": ["minecraft:latest"], "Created": 1606000000}
+		]`},
+		"GET /images/sha256:2222/json":                          {`{"Config": {"ExposedPorts": {"27015/udp": {}, "27015/tcp": {}}}}`},
+		"POST /containers/create?name=smg":                      {`{"Id": "` + testContainerId + `", "Warnings": []}`},
+		"POST /containers/" + testContainerId + "/start":        {""},
+		"DELETE /containers/" + testContainerId + "?force=true": {""},
+		containersKey("smg"): {`[{"Id": "` + testContainerId + `", "Names": ["/smg"], "State": "running",
+			"Status": "Up 1 second", "Ports": [{"PublicPort": 27015}]}]`},
+	}
+}
+
+func TestDockerCreateServer(t *testing.T) {
+	cloud, api := newTestDockerCloud(t, dockerCreateResponses())
+
+	snapshot, err := cloud.GetSnapshot("gmod")
+	if err != nil {
+		t.Fatalf("GetSnapshot failed: %v", err)
+	}
+	if snapshot.Name != "gmod 2020-11-20 18:30" {
+		t.Errorf("got snapshot %+v, expected the newest one taken on shutdown", snapshot)
+	}
+
+	server, err := cloud.CreateServer(CreateOptions{Name: "smg", Snapshot: snapshot})
+	if err != nil {
+		t.Fatalf("CreateServer failed: %v", err)
+	}
+	if server.Status != StatusActive || server.Ip != "127.0.0.1" {
+		t.Errorf("got server %+v, expected an active one on the published ports", server)
+	}
+
+	var request dockerCreateContainer
+	_ = json.Unmarshal([]byte(api.body("POST /containers/create?name=smg")), &request)
+	if request.Image != "sha256:2222" || len(request.HostConfig.PortBindings["27015/udp"]) != 1 ||
+		request.HostConfig.PortBindings["27015/udp"][0].HostPort != "27015" {
+		t.Errorf("got the container %+v", request)
+	}
+
+	for _, received := range api.received() {
+		if strings.HasPrefix(received, "DELETE") {
+			t.Errorf("the started container was removed")
+		}
+	}
+}
+
+func TestDockerCreateServerStartFailure(t *testing.T) {
+	startKey := "POST /containers/" + testContainerId + "/start"
+	responses := dockerCreateResponses()
+	responses[startKey] = []string{`{"message": "driver failed programming external connectivity: port is already allocated"}`}
+
+	cloud, api := newTestDockerCloud(t, responses)
+	api.respondWith(startKey, http.StatusInternalServerError)
+
+	snapshot, _ := cloud.GetSnapshot("gmod")
+	_, err := cloud.CreateServer(CreateOptions{Name: "smg", Snapshot: snapshot})
+	if err == nil || !strings.Contains(err.Error(), "port is already allocated") {
+		t.Fatalf("expected the error of the start, got %v", err)
+	}
+
+	// The container would block the name for the next creation
+	received := api.received()
+	if last := received[len(received)-1]; last != "DELETE /containers/"+testContainerId+"?force=true" {
+		t.Errorf("expected the created container to be removed, the last request was %v", last)
+	}
+}
+
+func TestSnapshotImageTag(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		repository string
+		tag        string
+	}{
+		{"gmod", "gmod", ""},
+		{"gmod:v2", "gmod", "v2"},
+		{"gmod 2020-11-20 18:30", "gmod", "2020-11-20-18-30"},
+		{"registry:5000/gmod 2020-11-20 18:30", "registry:5000/gmod", "2020-11-20-18-30"},
+	} {
+		repository, tag := snapshotImageTag(test.name)
+		if repository != test.repository || tag != test.tag {
+			t.Errorf("%q was split into %q and %q, expected %q and %q", test.name, repository, tag,
+				test.repository, test.tag)
+		}
+	}
+
+	if !sameImage("gmod", "gmod:latest") || sameImage("gmod:v2", "gmod") || sameImage("registry:5000/gmod", "gmod") {
+		t.Errorf("the image references were compared wrong")
+	}
+}
@@ -0,0 +1,322 @@
+package cloud
+
+import (
+	"fmt"
+	"start-my-game/lib/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An in-memory provider which doesn't cost anything, useful for CI and local testing
+const fakeProvider string = "Fake"
+
+type FakeCloud struct {
+	mutex     sync.Mutex
+	latency   time.Duration
+	bootTime  time.Duration
+	ip        string
+	price     float64
+	failures  map[string]error
+	sshKeys   map[string]int
+	snapshots []*Snapshot
+	servers   map[int]*fakeServer
+	nextId    int
+}
+
+type fakeServer struct {
+	server Server
+	// The server switches from StatusStartup to StatusActive at this time
+	activeAt time.Time
+}
+
+func (cloud *FakeCloud) GetProvider() string {
+	return fakeProvider
+}
+
+func (cloud *FakeCloud) GetSSHKey(fingerprint string) (int, error) {
+	if err := cloud.call("GetSSHKey"); err != nil {
+		return 0, err
+	}
+	defer cloud.mutex.Unlock()
+
+	id, ok := cloud.sshKeys[fingerprint]
+	if !ok {
+		id = cloud.newId()
+		cloud.sshKeys[fingerprint] = id
+	}
+
+	return id, nil
+}
+
+func (cloud *FakeCloud) GetSnapshot(name string) (*Snapshot, error) {
+	if err := cloud.call("GetSnapshot"); err != nil {
+		return nil, err
+	}
+	defer cloud.mutex.Unlock()
+
+	snapshots := cloud.listSnapshots(name)
+	if len(snapshots) == 0 {
+		return nil, newNotExistsError("snapshot", name, nil)
+	}
+
+	return newestSnapshot(snapshots), nil
+}
+
+func (cloud *FakeCloud) ListSnapshots(name string) ([]*Snapshot, error) {
+	if err := cloud.call("ListSnapshots"); err != nil {
+		return nil, err
+	}
+	defer cloud.mutex.Unlock()
+
+	return cloud.listSnapshots(name), nil
+}
+
+func (cloud *FakeCloud) DeleteSnapshot(snapshot *Snapshot) error {
+	if err := cloud.call("DeleteSnapshot"); err != nil {
+		return err
+	}
+	defer cloud.mutex.Unlock()
+
+	for i, existing := range cloud.snapshots {
+		if existing.Id == snapshot.Id {
+			cloud.snapshots = append(cloud.snapshots[:i], cloud.snapshots[i+1:]...)
+			return nil
+		}
+	}
+
+	return newNotExistsError("snapshot", snapshot.Name, nil)
+}
+
+// Returns copies of the matching snapshots, the mutex must be locked while calling this method
+func (cloud *FakeCloud) listSnapshots(name string) []*Snapshot {
+	var snapshots []*Snapshot
+
+	for _, snapshot := range cloud.snapshots {
+		if !matchesSnapshot(snapshot.Name, name) {
+			continue
+		}
+
+		copied := *snapshot
+		snapshots = append(snapshots, &copied)
+	}
+
+	return snapshots
+}
+
+func (cloud *FakeCloud) HourlyPrice(serverType string, region string) (float64, error) {
+	if err := cloud.call("HourlyPrice"); err != nil {
+		return 0, err
+	}
+	defer cloud.mutex.Unlock()
+
+	return cloud.price, nil
+}
+
+func (cloud *FakeCloud) GetServer(name string) (*Server, error) {
+	if err := cloud.call("GetServer"); err != nil {
+		return nil, err
+	}
+	defer cloud.mutex.Unlock()
+
+	lowerName := strings.ToLower(name)
+
+	for _, fake := range cloud.servers {
+		if strings.ToLower(fake.server.Name) != lowerName {
+			continue
+		}
+
+		return fake.current(), nil
+	}
+
+	return nil, newNotExistsError("server", name, nil)
+}
+
+func (cloud *FakeCloud) StartServer(server *Server) error {
+	if err := cloud.call("StartServer"); err != nil {
+		return err
+	}
+	defer cloud.mutex.Unlock()
+
+	fake, ok := cloud.servers[server.Id]
+	if !ok {
+		return newNotExistsError("server", server.Name, nil)
+	}
+
+	if status := fake.current().Status; status != StatusOff {
+		return fmt.Errorf("can't power on server %v with status %v", server.Name, status)
+	}
+
+	fake.server.Status = StatusStartup
+	fake.activeAt = time.Now().Add(cloud.bootTime)
+
+	return nil
+}
+
+func (cloud *FakeCloud) StopServer(server *Server) error {
+	if err := cloud.call("StopServer"); err != nil {
+		return err
+	}
+	defer cloud.mutex.Unlock()
+
+	fake, ok := cloud.servers[server.Id]
+	if !ok {
+		return newNotExistsError("server", server.Name, nil)
+	}
+
+	fake.server.Status = StatusOff
+
+	return nil
+}
+
+func (cloud *FakeCloud) CreateServer(options CreateOptions) (*Server, error) {
+	if err := cloud.call("CreateServer"); err != nil {
+		return nil, err
+	}
+	defer cloud.mutex.Unlock()
+
+	for _, fake := range cloud.servers {
+		if strings.ToLower(fake.server.Name) == strings.ToLower(options.Name) {
+			return nil, fmt.Errorf("couldn't create server: name '%v' is already used", options.Name)
+		}
+	}
+
+	if options.Snapshot == nil {
+		return nil, fmt.Errorf("couldn't create server: no snapshot given")
+	}
+
+	fake := &fakeServer{
+		server: Server{
+			Name:     options.Name,
+			Id:       cloud.newId(),
+			Ip:       cloud.ip,
+			Status:   StatusStartup,
+			Provider: fakeProvider,
+		},
+		activeAt: time.Now().Add(cloud.bootTime),
+	}
+	cloud.servers[fake.server.Id] = fake
+
+	return fake.current(), nil
+}
+
+func (cloud *FakeCloud) DestroyServer(server *Server) error {
+	if err := cloud.call("DestroyServer"); err != nil {
+		return err
+	}
+	defer cloud.mutex.Unlock()
+
+	if _, ok := cloud.servers[server.Id]; !ok {
+		return newNotExistsError("server", server.Name, nil)
+	}
+
+	delete(cloud.servers, server.Id)
+	server.Status = StatusDestroyed
+
+	return nil
+}
+
+func (cloud *FakeCloud) CreateSnapshot(server *Server, name string) (*Snapshot, error) {
+	if err := cloud.call("CreateSnapshot"); err != nil {
+		return nil, err
+	}
+	defer cloud.mutex.Unlock()
+
+	if _, ok := cloud.servers[server.Id]; !ok {
+		return nil, newNotExistsError("server", server.Name, nil)
+	}
+
+	return cloud.addSnapshot(name), nil
+}
+
+// Makes every following call of the method (e.g. "CreateServer") return the error
+func (cloud *FakeCloud) Fail(method string, err error) {
+	cloud.mutex.Lock()
+	defer cloud.mutex.Unlock()
+
+	cloud.failures[method] = err
+}
+
+// Removes a failure injected with Fail
+func (cloud *FakeCloud) Recover(method string) {
+	cloud.mutex.Lock()
+	defer cloud.mutex.Unlock()
+
+	delete(cloud.failures, method)
+}
+
+func (cloud *FakeCloud) AddSnapshot(name string) *Snapshot {
+	cloud.mutex.Lock()
+	defer cloud.mutex.Unlock()
+
+	return cloud.addSnapshot(name)
+}
+
+// The mutex must be locked while calling this method
+func (cloud *FakeCloud) addSnapshot(name string) *Snapshot {
+	snapshot := &Snapshot{Name: name, Id: cloud.newId(), Created: time.Now()}
+	cloud.snapshots = append(cloud.snapshots, snapshot)
+
+	copied := *snapshot
+	return &copied
+}
+
+// Simulates the latency of an API call and locks the cloud if there's no injected failure.
+// The caller has to unlock the mutex if no error is returned.
+func (cloud *FakeCloud) call(method string) error {
+	time.Sleep(cloud.latency)
+
+	cloud.mutex.Lock()
+	if err, ok := cloud.failures[method]; ok {
+		cloud.mutex.Unlock()
+		return fmt.Errorf("fake %v failed: %v", method, err)
+	}
+
+	return nil
+}
+
+// The mutex must be locked while calling this method
+func (cloud *FakeCloud) newId() int {
+	cloud.nextId++
+	return cloud.nextId
+}
+
+func (fake *fakeServer) current() *Server {
+	if fake.server.Status == StatusStartup && !time.Now().Before(fake.activeAt) {
+		fake.server.Status = StatusActive
+	}
+
+	copied := fake.server
+	return &copied
+}
+
+func newFakeCloud(cfg *config.Config) *FakeCloud {
+	fakeCfg := config.Fake{}
+	if cfg.Cloud.Fake != nil {
+		fakeCfg = *cfg.Cloud.Fake
+	}
+
+	ip := fakeCfg.Ip
+	if ip == "" {
+		ip = "127.0.0.1"
+	}
+
+	cloud := &FakeCloud{
+		latency:  time.Duration(fakeCfg.Latency) * time.Millisecond,
+		bootTime: time.Duration(fakeCfg.BootTime) * time.Second,
+		ip:       ip,
+		price:    fakeCfg.HourlyPrice,
+		failures: make(map[string]error),
+		sshKeys:  make(map[string]int),
+		servers:  make(map[int]*fakeServer),
+	}
+
+	for _, method := range fakeCfg.Failures {
+		cloud.failures[method] = fmt.Errorf("injected by config")
+	}
+
+	// The configured snapshot always exists
+	cloud.AddSnapshot(cfg.Cloud.Snapshot)
+
+	return cloud
+}
@@ -0,0 +1,140 @@
+package cloud
+
+import (
+	"fmt"
+	"start-my-game/lib/config"
+	"testing"
+	"time"
+)
+
+func newTestConfig(provider string) *config.Config {
+	cfg := &config.Config{}
+	cfg.Cloud.Provider = provider
+	cfg.Cloud.ServerName = "smg-test"
+	cfg.Cloud.Snapshot = "gmod"
+	cfg.Cloud.SshKey = "a0:83:be:8a:5c:38:22:36:b7:11:96:0f:d1:63:23:9c"
+
+	return cfg
+}
+
+func TestFakeLifecycle(t *testing.T) {
+	cfg := newTestConfig("fake")
+	cfg.Cloud.Fake = &config.Fake{Ip: "192.0.2.1"}
+
+	cloud := newFakeCloud(cfg)
+	cloud.bootTime = 50 * time.Millisecond
+
+	expectStatus := func(expected string) *Server {
+		t.Helper()
+
+		server, err := cloud.GetServer("smg-test")
+		if err != nil {
+			t.Fatalf("GetServer failed: %v", err)
+		}
+		if server.Status != expected {
+			t.Fatalf("got status %v, expected %v", server.Status, expected)
+		}
+
+		return server
+	}
+
+	_, err := cloud.GetServer("smg-test")
+	if !IsNotExistsError(err) {
+		t.Fatalf("expected a not exists error before the creation, got %v", err)
+	}
+
+	key, err := cloud.GetSSHKey(cfg.Cloud.SshKey)
+	if err != nil {
+		t.Fatalf("GetSSHKey failed: %v", err)
+	}
+
+	snapshot, err := cloud.GetSnapshot("gmod")
+	if err != nil {
+		t.Fatalf("GetSnapshot failed: %v", err)
+	}
+
+	server, err := cloud.CreateServer(CreateOptions{Name: "smg-test", Snapshot: snapshot, SshKey: key})
+	if err != nil {
+		t.Fatalf("CreateServer failed: %v", err)
+	}
+	if server.Status != StatusStartup || server.Ip != "192.0.2.1" {
+		t.Errorf("got server %+v, expected it to start with the configured ip", server)
+	}
+
+	time.Sleep(cloud.bootTime)
+	server = expectStatus(StatusActive)
+
+	err = cloud.StopServer(server)
+	if err != nil {
+		t.Fatalf("StopServer failed: %v", err)
+	}
+	server = expectStatus(StatusOff)
+
+	// The server is started again, like the manager does for an existing server
+	err = cloud.StartServer(server)
+	if err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+	expectStatus(StatusStartup)
+	time.Sleep(cloud.bootTime)
+	server = expectStatus(StatusActive)
+
+	_, err = cloud.CreateSnapshot(server, SnapshotName("gmod", time.Now()))
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	err = cloud.DestroyServer(server)
+	if err != nil {
+		t.Fatalf("DestroyServer failed: %v", err)
+	}
+	if server.Status != StatusDestroyed {
+		t.Errorf("got status %v after the destruction, expected %v", server.Status, StatusDestroyed)
+	}
+
+	_, err = cloud.GetServer("smg-test")
+	if !IsNotExistsError(err) {
+		t.Errorf("expected a not exists error after the destruction, got %v", err)
+	}
+
+	snapshots, err := cloud.ListSnapshots("gmod")
+	if err != nil {
+		t.Fatalf("ListSnapshots failed: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Errorf("got %v snapshots, expected the configured one and the one taken", len(snapshots))
+	}
+}
+
+func TestFakeFailures(t *testing.T) {
+	cfg := newTestConfig("fake")
+	cfg.Cloud.Fake = &config.Fake{Failures: []string{"CreateServer"}}
+
+	cloud := newFakeCloud(cfg)
+
+	snapshot, err := cloud.GetSnapshot("gmod")
+	if err != nil {
+		t.Fatalf("GetSnapshot failed: %v", err)
+	}
+
+	_, err = cloud.CreateServer(CreateOptions{Name: "smg-test", Snapshot: snapshot})
+	if err == nil {
+		t.Fatalf("expected the configured failure of CreateServer")
+	}
+
+	cloud.Recover("CreateServer")
+	server, err := cloud.CreateServer(CreateOptions{Name: "smg-test", Snapshot: snapshot})
+	if err != nil {
+		t.Fatalf("CreateServer failed after the recovery: %v", err)
+	}
+
+	cloud.Fail("DestroyServer", fmt.Errorf("quota exceeded"))
+	err = cloud.DestroyServer(server)
+	if err == nil {
+		t.Errorf("expected the injected failure of DestroyServer")
+	}
+
+	if _, err := cloud.GetServer("smg-test"); err != nil {
+		t.Errorf("the server should still exist after the failed destruction: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/hetznercloud/hcloud-go/hcloud"
+	"strconv"
 	"strings"
 )
 
@@ -29,12 +30,25 @@ func (cloud *HCloud) GetSSHKey(fingerprint string) (int, error) {
 }
 
 func (cloud *HCloud) GetSnapshot(name string) (*Snapshot, error) {
+	snapshots, err := cloud.ListSnapshots(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) == 0 {
+		return nil, newNotExistsError("image", name, nil)
+	}
+
+	return newestSnapshot(snapshots), nil
+}
+
+func (cloud *HCloud) ListSnapshots(name string) ([]*Snapshot, error) {
 	images, err := cloud.client.Image.All(cloud.context)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get the image: %v", err)
 	}
 
-	lowerName := strings.ToLower(name)
+	var snapshots []*Snapshot
 
 	for _, image := range images {
 
@@ -42,17 +56,49 @@ func (cloud *HCloud) GetSnapshot(name string) (*Snapshot, error) {
 			continue
 		}
 
-		if strings.ToLower(image.Description) != lowerName {
+		if !matchesSnapshot(image.Description, name) {
 			continue
 		}
 
-		return &Snapshot{
-			Name: image.Description,
-			Id:   image.ID,
-		}, nil
+		snapshots = append(snapshots, toCloudSnapshot(image))
 	}
 
-	return nil, newNotExistsError("image", name, nil)
+	return snapshots, nil
+}
+
+func (cloud *HCloud) DeleteSnapshot(snapshot *Snapshot) error {
+	_, err := cloud.client.Image.Delete(cloud.context, &hcloud.Image{ID: snapshot.Id})
+	if err != nil {
+		return fmt.Errorf("couldn't delete snapshot: %v", err)
+	}
+
+	return nil
+}
+
+// Uses the price of the region, or the first price if there's none for the region
+func (cloud *HCloud) HourlyPrice(serverType string, region string) (float64, error) {
+	hServerType, _, err := cloud.client.ServerType.GetByName(cloud.context, serverType)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get the server type: %v", err)
+	}
+
+	if hServerType == nil || len(hServerType.Pricings) == 0 {
+		return 0, newNotExistsError("server type", serverType, nil)
+	}
+
+	pricing := hServerType.Pricings[0]
+	for _, locationPricing := range hServerType.Pricings {
+		if locationPricing.Location != nil && strings.EqualFold(locationPricing.Location.Name, region) {
+			pricing = locationPricing
+		}
+	}
+
+	price, err := strconv.ParseFloat(pricing.Hourly.Gross, 64)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't read the price '%v': %v", pricing.Hourly.Gross, err)
+	}
+
+	return price, nil
 }
 
 func (cloud *HCloud) GetServer(name string) (*Server, error) {
@@ -123,6 +169,40 @@ func (cloud *HCloud) DestroyServer(server *Server) error {
 	return nil
 }
 
+func (cloud *HCloud) CreateSnapshot(server *Server, name string) (*Snapshot, error) {
+	ctx, cancel := context.WithTimeout(cloud.context, snapshotTimeout)
+	defer cancel()
+
+	result, _, err := cloud.client.Server.CreateImage(ctx, &hcloud.Server{ID: server.Id}, &hcloud.ServerCreateImageOpts{
+		Type:        hcloud.ImageTypeSnapshot,
+		Description: &name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create snapshot: %v", err)
+	}
+
+	_, errCh := cloud.client.Action.WatchProgress(ctx, result.Action)
+	err = <-errCh
+	if err != nil {
+		return nil, fmt.Errorf("couldn't finish snapshot of server %v: %v", server.Name, err)
+	}
+
+	image, _, err := cloud.client.Image.GetByID(ctx, result.Image.ID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get the created snapshot: %v", err)
+	}
+
+	return toCloudSnapshot(image), nil
+}
+
+func toCloudSnapshot(image *hcloud.Image) *Snapshot {
+	return &Snapshot{
+		Name:    image.Description,
+		Id:      image.ID,
+		Created: image.Created,
+	}
+}
+
 func (cloud *HCloud) toCloudServer(server *hcloud.Server) *Server {
 	status := StatusOff
 
@@ -0,0 +1,64 @@
+package cloud
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+)
+
+// Maps the string ids of some providers to the integer ids of servers, snapshots and keys.
+// The integers stay the same after a restart, because they are derived from the strings.
+type idMap struct {
+	mutex sync.Mutex
+	ids   map[int]string
+}
+
+func (ids *idMap) add(id string) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(id))
+	// Positive on 32 bit systems too
+	number := int(hash.Sum32() >> 1)
+
+	ids.mutex.Lock()
+	defer ids.mutex.Unlock()
+	ids.ids[number] = id
+
+	return number
+}
+
+// Only returns ids which were added before
+func (ids *idMap) get(number int) (string, bool) {
+	ids.mutex.Lock()
+	defer ids.mutex.Unlock()
+
+	id, ok := ids.ids[number]
+	return id, ok
+}
+
+func newIdMap() *idMap {
+	return &idMap{ids: make(map[int]string)}
+}
+
+// The MD5 fingerprint of a public key in the authorized_keys format, like the other providers show it
+func keyFingerprint(publicKey string) (string, error) {
+	fields := strings.Fields(publicKey)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("invalid public key")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %v", err)
+	}
+
+	sum := md5.Sum(decoded)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+
+	return strings.Join(parts, ":"), nil
+}
@@ -0,0 +1,478 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"start-my-game/lib/config"
+	"strings"
+	"time"
+)
+
+// Runs the server as a virtual machine of a local hypervisor using the commands virsh and qemu-img.
+// The snapshots are base images, which are never changed by the servers.
+// https://libvirt.org/manpages/virsh.html
+const libvirtProvider string = "Libvirt"
+
+const libvirtUri = "qemu:///system"
+
+const libvirtImageExtension = ".qcow2"
+
+// Maximum duration of a virsh call
+const libvirtTimeout = time.Minute
+
+// Maximum duration to wait for the server to power off before taking a snapshot
+const libvirtShutdownTimeout = 2 * time.Minute
+
+type LibvirtCloud struct {
+	uri      string
+	imageDir string
+	network  string
+	memory   int
+	cpus     int
+	// Maps the ids to the paths of base images and the UUIDs of domains
+	ids *idMap
+}
+
+type libvirtDomain struct {
+	XMLName xml.Name `xml:"domain"`
+	Type    string   `xml:"type,attr"`
+	Name    string   `xml:"name"`
+	Memory  struct {
+		Unit  string `xml:"unit,attr"`
+		Value int    `xml:",chardata"`
+	} `xml:"memory"`
+	Cpus int `xml:"vcpu"`
+	Os   struct {
+		Type string `xml:"type"`
+		Boot struct {
+			Dev string `xml:"dev,attr"`
+		} `xml:"boot"`
+	} `xml:"os"`
+	// Needed for a graceful shutdown
+	Features struct {
+		Acpi struct{} `xml:"acpi"`
+	} `xml:"features"`
+	Devices struct {
+		Disk struct {
+			Type   string `xml:"type,attr"`
+			Device string `xml:"device,attr"`
+			Driver struct {
+				Name string `xml:"name,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"driver"`
+			Source struct {
+				File string `xml:"file,attr"`
+			} `xml:"source"`
+			Target struct {
+				Dev string `xml:"dev,attr"`
+				Bus string `xml:"bus,attr"`
+			} `xml:"target"`
+		} `xml:"disk"`
+		Interface struct {
+			Type   string `xml:"type,attr"`
+			Source struct {
+				Network string `xml:"network,attr"`
+			} `xml:"source"`
+			Model struct {
+				Type string `xml:"type,attr"`
+			} `xml:"model"`
+		} `xml:"interface"`
+	} `xml:"devices"`
+}
+
+func (cloud *LibvirtCloud) GetProvider() string {
+	return libvirtProvider
+}
+
+// The base images already contain the ssh keys
+func (cloud *LibvirtCloud) GetSSHKey(fingerprint string) (int, error) {
+	return 0, nil
+}
+
+func (cloud *LibvirtCloud) GetSnapshot(name string) (*Snapshot, error) {
+	snapshots, err := cloud.ListSnapshots(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) == 0 {
+		return nil, newNotExistsError("snapshot", name, nil)
+	}
+
+	return newestSnapshot(snapshots), nil
+}
+
+func (cloud *LibvirtCloud) ListSnapshots(name string) ([]*Snapshot, error) {
+	files, err := ioutil.ReadDir(cloud.imageDir)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list images: %v", err)
+	}
+
+	var snapshots []*Snapshot
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), libvirtImageExtension) {
+			continue
+		}
+
+		imageName := strings.TrimSuffix(file.Name(), libvirtImageExtension)
+		if !matchesSnapshot(imageName, libvirtFileName(name)) {
+			continue
+		}
+
+		snapshots = append(snapshots, &Snapshot{
+			Name:    imageName,
+			Id:      cloud.ids.add(filepath.Join(cloud.imageDir, file.Name())),
+			Created: file.ModTime(),
+		})
+	}
+
+	return snapshots, nil
+}
+
+func (cloud *LibvirtCloud) DeleteSnapshot(snapshot *Snapshot) error {
+	path, ok := cloud.ids.get(snapshot.Id)
+	if !ok {
+		return newNotExistsError("snapshot", snapshot.Name, nil)
+	}
+
+	err := os.Remove(path)
+	if err != nil {
+		return fmt.Errorf("couldn't delete image: %v", err)
+	}
+
+	return nil
+}
+
+// A domain in shutdown is already reported as off, the game can't be reached anymore
+func (cloud *LibvirtCloud) GetServer(name string) (*Server, error) {
+	state, err := cloud.domainState(name)
+	if err != nil {
+		return nil, err
+	}
+
+	uuid, err := cloud.virsh("domuuid", name)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get domain: %v", err)
+	}
+
+	status := StatusOff
+	ip := ""
+
+	switch state {
+	case "running":
+		// The game can't be reached until the server got its address
+		ip = cloud.leasedIp(name)
+		if ip != "" {
+			status = StatusActive
+		} else {
+			status = StatusStartup
+		}
+	}
+
+	return &Server{
+		Name:     name,
+		Id:       cloud.ids.add(strings.TrimSpace(uuid)),
+		Ip:       ip,
+		Status:   status,
+		Provider: libvirtProvider,
+	}, nil
+}
+
+func (cloud *LibvirtCloud) StartServer(server *Server) error {
+	_, err := cloud.virsh("start", server.Name)
+	if err != nil {
+		return fmt.Errorf("couldn't start domain: %v", err)
+	}
+
+	return nil
+}
+
+func (cloud *LibvirtCloud) StopServer(server *Server) error {
+	_, err := cloud.virsh("shutdown", server.Name)
+	if err != nil {
+		return fmt.Errorf("couldn't shutdown domain: %v", err)
+	}
+
+	return nil
+}
+
+// Creates an overlay of the base image and a domain using it. The machine type and region aren't used.
+func (cloud *LibvirtCloud) CreateServer(options CreateOptions) (*Server, error) {
+	base, ok := cloud.ids.get(options.Snapshot.Id)
+	if !ok {
+		return nil, newNotExistsError("snapshot", options.Snapshot.Name, nil)
+	}
+
+	disk := cloud.diskPath(options.Name)
+	err := os.MkdirAll(filepath.Dir(disk), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create the directory of the disks: %v", err)
+	}
+
+	_, err = run(libvirtTimeout, "qemu-img", "create", "-f", "qcow2", "-F", "qcow2", "-b", base, disk)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create disk: %v", err)
+	}
+
+	definition, err := xml.Marshal(cloud.domain(options.Name, disk))
+	if err != nil {
+		return nil, cloud.discard(options.Name, false, fmt.Errorf("couldn't compose domain: %v", err))
+	}
+
+	file, err := ioutil.TempFile("", "smg-domain-*.xml")
+	if err != nil {
+		return nil, cloud.discard(options.Name, false, fmt.Errorf("couldn't write domain: %v", err))
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(definition)
+	_ = file.Close()
+	if err != nil {
+		return nil, cloud.discard(options.Name, false, fmt.Errorf("couldn't write domain: %v", err))
+	}
+
+	_, err = cloud.virsh("define", file.Name())
+	if err != nil {
+		return nil, cloud.discard(options.Name, false, fmt.Errorf("couldn't define domain: %v", err))
+	}
+
+	_, err = cloud.virsh("start", options.Name)
+	if err != nil {
+		return nil, cloud.discard(options.Name, true, fmt.Errorf("couldn't start domain: %v", err))
+	}
+
+	return cloud.GetServer(options.Name)
+}
+
+// Removes the disk and the domain, if it was defined, of a failed creation. Returns the error
+// of the creation, which contains the errors of the removal.
+func (cloud *LibvirtCloud) discard(name string, defined bool, err error) error {
+	var failures []string
+
+	if defined {
+		_, undefineErr := cloud.virsh("undefine", name)
+		if undefineErr != nil {
+			failures = append(failures, fmt.Sprintf("couldn't undefine domain: %v", undefineErr))
+		}
+	}
+
+	removeErr := os.Remove(cloud.diskPath(name))
+	if removeErr != nil && !os.IsNotExist(removeErr) {
+		failures = append(failures, fmt.Sprintf("couldn't delete disk: %v", removeErr))
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%v (%v)", err, strings.Join(failures, ", "))
+	}
+
+	return err
+}
+
+// Powers off and undefines the domain and deletes its disk
+func (cloud *LibvirtCloud) DestroyServer(server *Server) error {
+	// The status is off while the domain shuts down, destroying a domain which is already off fails
+	_, err := cloud.virsh("destroy", server.Name)
+	if err != nil {
+		state, stateErr := cloud.domainState(server.Name)
+		if stateErr != nil || state != "shut off" {
+			return fmt.Errorf("couldn't power off domain: %v", err)
+		}
+	}
+
+	_, err = cloud.virsh("undefine", server.Name)
+	if err != nil {
+		return fmt.Errorf("couldn't undefine domain: %v", err)
+	}
+
+	err = os.Remove(cloud.diskPath(server.Name))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("couldn't delete disk: %v", err)
+	}
+
+	return nil
+}
+
+// Merges the disk of the server and its base image into a new base image
+func (cloud *LibvirtCloud) CreateSnapshot(server *Server, name string) (*Snapshot, error) {
+	deadline := time.Now().Add(libvirtShutdownTimeout)
+	for {
+		state, err := cloud.domainState(server.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		// The disk is still used while the domain shuts down
+		if state == "shut off" {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("domain %v is still running, the disk can't be copied", server.Name)
+		}
+		time.Sleep(snapshotPollInterval)
+	}
+
+	path := filepath.Join(cloud.imageDir, libvirtFileName(name)+libvirtImageExtension)
+	// The image isn't listed until it's complete
+	incomplete := path + ".part"
+
+	_, err := run(snapshotTimeout, "qemu-img", "convert", "-O", "qcow2", cloud.diskPath(server.Name), incomplete)
+	if err != nil {
+		_ = os.Remove(incomplete)
+		return nil, fmt.Errorf("couldn't convert disk: %v", err)
+	}
+
+	err = os.Rename(incomplete, path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't move image: %v", err)
+	}
+
+	return &Snapshot{
+		Name:    libvirtFileName(name),
+		Id:      cloud.ids.add(path),
+		Created: time.Now(),
+	}, nil
+}
+
+// The state of the domain like 'running' or 'shut off'
+func (cloud *LibvirtCloud) domainState(name string) (string, error) {
+	output, err := cloud.virsh("domstate", name)
+	if err == nil {
+		return strings.TrimSpace(output), nil
+	}
+
+	// virsh fails the same way for all errors, the list tells whether the domain exists
+	names, listErr := cloud.virsh("list", "--all", "--name")
+	if listErr != nil {
+		return "", fmt.Errorf("couldn't get domain: %v", err)
+	}
+
+	for _, line := range strings.Split(names, "\n") {
+		if strings.TrimSpace(line) == name {
+			return "", fmt.Errorf("couldn't get domain: %v", err)
+		}
+	}
+
+	return "", newNotExistsError("server", name, err)
+}
+
+// Returns the IPv4 address from the DHCP leases of the network or an empty string
+func (cloud *LibvirtCloud) leasedIp(name string) string {
+	output, err := cloud.virsh("domifaddr", name, "--source", "lease")
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		// The columns are the interface, the mac address, the protocol and the address
+		fields := strings.Fields(line)
+		if len(fields) == 4 && fields[2] == "ipv4" {
+			return strings.SplitN(fields[3], "/", 2)[0]
+		}
+	}
+
+	return ""
+}
+
+func (cloud *LibvirtCloud) diskPath(name string) string {
+	return filepath.Join(cloud.imageDir, "servers", libvirtFileName(name)+libvirtImageExtension)
+}
+
+func (cloud *LibvirtCloud) domain(name string, disk string) *libvirtDomain {
+	domain := &libvirtDomain{Type: "kvm", Name: name, Cpus: cloud.cpus}
+	// The test driver only accepts its own type
+	if strings.HasPrefix(cloud.uri, "test:") {
+		domain.Type = "test"
+	}
+
+	domain.Memory.Unit = "MiB"
+	domain.Memory.Value = cloud.memory
+	domain.Os.Type = "hvm"
+	domain.Os.Boot.Dev = "hd"
+
+	domain.Devices.Disk.Type = "file"
+	domain.Devices.Disk.Device = "disk"
+	domain.Devices.Disk.Driver.Name = "qemu"
+	domain.Devices.Disk.Driver.Type = "qcow2"
+	domain.Devices.Disk.Source.File = disk
+	domain.Devices.Disk.Target.Dev = "vda"
+	domain.Devices.Disk.Target.Bus = "virtio"
+
+	domain.Devices.Interface.Type = "network"
+	domain.Devices.Interface.Source.Network = cloud.network
+	domain.Devices.Interface.Model.Type = "virtio"
+
+	return domain
+}
+
+// Runs the virsh command with a new connection to the hypervisor
+func (cloud *LibvirtCloud) virsh(args ...string) (string, error) {
+	return run(libvirtTimeout, "virsh", append([]string{"--connect", cloud.uri}, args...)...)
+}
+
+// Runs the command and returns its output, the error contains the error output
+func run(timeout time.Duration, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	command := exec.CommandContext(ctx, name, args...)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+
+	err := command.Run()
+	if err != nil {
+		return "", fmt.Errorf("%v %v: %v", name, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// Colons and slashes can't be used in file names of images
+func libvirtFileName(name string) string {
+	return strings.NewReplacer(":", "-", "/", "-").Replace(name)
+}
+
+func newLibvirtCloud(cfg *config.Config) (*LibvirtCloud, error) {
+	libvirtCfg := config.Libvirt{}
+	if cfg.Cloud.Libvirt != nil {
+		libvirtCfg = *cfg.Cloud.Libvirt
+	}
+
+	if libvirtCfg.ImageDir == "" {
+		return nil, fmt.Errorf("the libvirt provider needs a directory for the images")
+	}
+
+	uri := cfg.Cloud.Endpoint
+	if uri == "" {
+		uri = libvirtUri
+	}
+
+	cloud := &LibvirtCloud{
+		uri:      uri,
+		imageDir: libvirtCfg.ImageDir,
+		network:  libvirtCfg.Network,
+		memory:   libvirtCfg.Memory,
+		cpus:     libvirtCfg.Cpus,
+		ids:      newIdMap(),
+	}
+
+	if cloud.network == "" {
+		cloud.network = "default"
+	}
+	if cloud.memory <= 0 {
+		cloud.memory = 2048
+	}
+	if cloud.cpus <= 0 {
+		cloud.cpus = 2
+	}
+
+	return cloud, nil
+}
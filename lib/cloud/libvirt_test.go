@@ -0,0 +1,425 @@
+package cloud
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"start-my-game/lib/config"
+	"strings"
+	"testing"
+)
+
+// Replaces the commands, virsh and qemu-img by default, by the fake commands of TestHelperProcess until
+// the end of the test. The fake virsh keeps the domains in a file and fails the command named by the
+// variable SMG_FAKE_FAIL.
+func installFakeCommands(t *testing.T, fail string, names ...string) {
+	dir, err := ioutil.TempDir("", "smg-fake-commands")
+	if err != nil {
+		t.Fatalf("couldn't create the directory of the fake commands: %v", err)
+	}
+
+	if len(names) == 0 {
+		names = []string{"virsh", "qemu-img"}
+	}
+
+	for _, name := range names {
+		script := fmt.Sprintf("#!/bin/sh\nSMG_FAKE_COMMAND=%v SMG_FAKE_FAIL='%v' SMG_FAKE_STATE='%v' exec '%v' -test.run='^TestHelperProcess$' -- \"$@\"\n",
+			name, fail, filepath.Join(dir, "domains.json"), os.Args[0])
+
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755)
+		if err != nil {
+			t.Fatalf("couldn't write the fake command %v: %v", name, err)
+		}
+	}
+
+	path := os.Getenv("PATH")
+	_ = os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	t.Cleanup(func() {
+		_ = os.Setenv("PATH", path)
+		_ = os.RemoveAll(dir)
+	})
+}
+
+// Creates a cloud with a base image named 'gmod'
+func newTestLibvirtCloud(t *testing.T) *LibvirtCloud {
+	dir, err := ioutil.TempDir("", "smg-libvirt")
+	if err != nil {
+		t.Fatalf("couldn't create the image directory: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	cfg := &config.Config{}
+	cfg.Cloud.Endpoint = "test:///default"
+	cfg.Cloud.Libvirt = &config.Libvirt{ImageDir: dir, Memory: 128, Cpus: 1}
+
+	cloud, err := newLibvirtCloud(cfg)
+	if err != nil {
+		t.Fatalf("couldn't create the cloud: %v", err)
+	}
+
+	// The test driver doesn't read the disk, qemu-img only needs a valid image
+	_, err = run(libvirtTimeout, "qemu-img", "create", "-f", "qcow2", filepath.Join(dir, "gmod"+libvirtImageExtension), "16M")
+	if err != nil {
+		t.Fatalf("couldn't create the base image: %v", err)
+	}
+
+	return cloud
+}
+
+func createTestServer(t *testing.T, cloud *LibvirtCloud) (*Server, error) {
+	snapshot, err := cloud.GetSnapshot("gmod")
+	if err != nil {
+		t.Fatalf("GetSnapshot failed: %v", err)
+	}
+
+	return cloud.CreateServer(CreateOptions{Name: "smg-test", Snapshot: snapshot})
+}
+
+func TestLibvirtLifecycle(t *testing.T) {
+	installFakeCommands(t, "")
+	fastSnapshotPolling(t)
+	cloud := newTestLibvirtCloud(t)
+
+	server, err := createTestServer(t, cloud)
+	if err != nil {
+		t.Fatalf("CreateServer failed: %v", err)
+	}
+
+	if server.Status != StatusStartup || server.Ip != "" {
+		t.Errorf("got server %+v, expected it to start without an address", server)
+	}
+
+	server, err = cloud.GetServer("smg-test")
+	if err != nil {
+		t.Fatalf("GetServer failed: %v", err)
+	}
+	if server.Status != StatusActive || server.Ip != "192.168.122.10" {
+		t.Errorf("got server %+v, expected it to be active with the leased address", server)
+	}
+
+	err = cloud.StopServer(server)
+	if err != nil {
+		t.Fatalf("StopServer failed: %v", err)
+	}
+
+	server, err = cloud.GetServer("smg-test")
+	if err != nil {
+		t.Fatalf("GetServer failed: %v", err)
+	}
+	if server.Status != StatusOff {
+		t.Errorf("got status %v while the domain shuts down, expected %v", server.Status, StatusOff)
+	}
+
+	snapshot, err := cloud.CreateSnapshot(server, "gmod 2020-01-02 15:04")
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	snapshots, err := cloud.ListSnapshots("gmod")
+	if err != nil {
+		t.Fatalf("ListSnapshots failed: %v", err)
+	}
+	if len(snapshots) != 2 || snapshot.Name != "gmod 2020-01-02 15-04" {
+		t.Errorf("got snapshot %v and %v snapshots, expected 2", snapshot.Name, len(snapshots))
+	}
+
+	err = cloud.DestroyServer(server)
+	if err != nil {
+		t.Fatalf("DestroyServer failed: %v", err)
+	}
+
+	_, err = cloud.GetServer("smg-test")
+	if !IsNotExistsError(err) {
+		t.Errorf("expected a not exists error after the destruction, got %v", err)
+	}
+
+	if _, err := os.Stat(cloud.diskPath("smg-test")); !os.IsNotExist(err) {
+		t.Errorf("the disk of the server wasn't deleted: %v", err)
+	}
+}
+
+func TestLibvirtCreateServerCleanup(t *testing.T) {
+	installFakeCommands(t, "start")
+	cloud := newTestLibvirtCloud(t)
+
+	_, err := createTestServer(t, cloud)
+	if err == nil {
+		t.Fatalf("expected CreateServer to fail")
+	}
+
+	_, err = cloud.GetServer("smg-test")
+	if !IsNotExistsError(err) {
+		t.Errorf("expected the domain to be undefined, got %v", err)
+	}
+
+	if _, err := os.Stat(cloud.diskPath("smg-test")); !os.IsNotExist(err) {
+		t.Errorf("the disk of the failed server wasn't deleted: %v", err)
+	}
+}
+
+// Uses the real qemu-img to create and merge the disks, the images of the fake virsh are never read
+func TestLibvirtQemuImg(t *testing.T) {
+	if _, err := exec.LookPath("qemu-img"); err != nil {
+		t.Skip("qemu-img isn't installed")
+	}
+
+	installFakeCommands(t, "", "virsh")
+	fastSnapshotPolling(t)
+	cloud := newTestLibvirtCloud(t)
+
+	server, err := createTestServer(t, cloud)
+	if err != nil {
+		t.Fatalf("CreateServer failed: %v", err)
+	}
+
+	err = cloud.StopServer(server)
+	if err != nil {
+		t.Fatalf("StopServer failed: %v", err)
+	}
+
+	snapshot, err := cloud.CreateSnapshot(server, "gmod 2020-01-02 15:04")
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	path, _ := cloud.ids.get(snapshot.Id)
+	output, err := run(libvirtTimeout, "qemu-img", "info", path)
+	if err != nil {
+		t.Fatalf("couldn't read the snapshot: %v", err)
+	}
+
+	// The snapshot is a new base image
+	if !strings.Contains(output, "file format: qcow2") || strings.Contains(output, "backing file") {
+		t.Errorf("the snapshot isn't a standalone image:\n%v", output)
+	}
+
+	err = cloud.DestroyServer(server)
+	if err != nil {
+		t.Fatalf("DestroyServer failed: %v", err)
+	}
+}
+
+// Checks the output parsed by the provider against the real virsh. The test driver of libvirt has a
+// running domain named 'test', but forgets the changes after each connection.
+func TestVirshTestDriver(t *testing.T) {
+	if _, err := exec.LookPath("virsh"); err != nil {
+		t.Skip("virsh isn't installed")
+	}
+
+	cloud := &LibvirtCloud{uri: "test:///default", ids: newIdMap()}
+
+	server, err := cloud.GetServer("test")
+	if err != nil {
+		t.Fatalf("GetServer failed: %v", err)
+	}
+
+	uuid, _ := cloud.ids.get(server.Id)
+	if server.Status == StatusOff || uuid != "6695eb01-f6a4-8304-79aa-97f2502e193f" {
+		t.Errorf("got server %+v with the UUID %v, expected the running domain of the test driver", server, uuid)
+	}
+
+	_, err = cloud.GetServer("smg-missing")
+	if !IsNotExistsError(err) {
+		t.Errorf("expected a not exists error for a missing domain, got %v", err)
+	}
+
+	// The state of a missing domain can't tell whether it's already off
+	err = cloud.DestroyServer(&Server{Name: "smg-missing"})
+	if err == nil {
+		t.Errorf("expected DestroyServer to fail for a missing domain")
+	}
+}
+
+// Not a real test, it's the fake command started by installFakeCommands
+func TestHelperProcess(t *testing.T) {
+	command := os.Getenv("SMG_FAKE_COMMAND")
+	if command == "" {
+		return
+	}
+
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+
+	if command == "qemu-img" {
+		os.Exit(fakeQemuImg(args))
+	}
+
+	os.Exit(fakeVirsh(args, os.Getenv("SMG_FAKE_FAIL")))
+}
+
+// Only supports the arguments used by the provider
+func fakeQemuImg(args []string) int {
+	var err error
+
+	switch args[0] {
+	case "create":
+		// The options have a value, the file is the first other argument
+		var files []string
+		base := ""
+		for i := 1; i < len(args); i++ {
+			switch args[i] {
+			case "-f", "-F":
+				i++
+			case "-b":
+				i++
+				base = args[i]
+			default:
+				files = append(files, args[i])
+			}
+		}
+
+		if base != "" {
+			if _, err := os.Stat(base); err != nil {
+				fmt.Fprintf(os.Stderr, "qemu-img: could not open backing file: %v\n", err)
+				return 1
+			}
+		}
+		err = ioutil.WriteFile(files[0], []byte(strings.Join(args, " ")), 0644)
+	case "convert":
+		var content []byte
+		content, err = ioutil.ReadFile(args[len(args)-2])
+		if err == nil {
+			err = ioutil.WriteFile(args[len(args)-1], content, 0644)
+		}
+	default:
+		err = fmt.Errorf("unknown command %v", args[0])
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "qemu-img: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+type fakeDomain struct {
+	Uuid  string `json:"uuid"`
+	State string `json:"state"`
+	// The address is leased after the first query
+	Queries int `json:"queries"`
+}
+
+// Behaves like a call of virsh, which connects to the hypervisor only for the command. The domains
+// are kept in the file of the variable SMG_FAKE_STATE.
+func fakeVirsh(args []string, fail string) int {
+	domains := make(map[string]*fakeDomain)
+
+	state := os.Getenv("SMG_FAKE_STATE")
+	content, err := ioutil.ReadFile(state)
+	if err == nil {
+		err = json.Unmarshal(content, &domains)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "error: couldn't read the domains: %v\n", err)
+		return 1
+	}
+
+	// The connection is the only option used by the provider
+	if len(args) < 3 || args[0] != "--connect" {
+		fmt.Fprintf(os.Stderr, "error: unexpected arguments %v\n", args)
+		return 1
+	}
+	args = args[2:]
+
+	code := runFakeVirsh(args, fail, domains)
+
+	content, err = json.Marshal(domains)
+	if err == nil {
+		err = ioutil.WriteFile(state, content, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: couldn't write the domains: %v\n", err)
+		return 1
+	}
+
+	return code
+}
+
+func runFakeVirsh(args []string, fail string, domains map[string]*fakeDomain) int {
+	failed := func(format string, args ...interface{}) int {
+		fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+		return 1
+	}
+
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+	domain := domains[name]
+
+	switch {
+	case args[0] == "list":
+		for name := range domains {
+			fmt.Println(name)
+		}
+		fmt.Println()
+	case args[0] == "define":
+		var definition libvirtDomain
+		content, err := ioutil.ReadFile(name)
+		if err == nil {
+			err = xml.Unmarshal(content, &definition)
+		}
+		if err != nil {
+			failed("Failed to define domain from %v", name)
+			return failed("%v", err)
+		}
+
+		domains[definition.Name] = &fakeDomain{
+			Uuid:  fmt.Sprintf("6695eb01-f6a4-8304-79aa-%012d", len(domains)+1),
+			State: "shut off",
+		}
+		fmt.Printf("Domain '%v' defined from %v\n\n", definition.Name, name)
+	case domain == nil:
+		return failed("failed to get domain '%v'", name)
+	case args[0] == fail:
+		failed("Failed to %v domain '%v'", args[0], name)
+		return failed("internal error: fake failure")
+	case args[0] == "domstate":
+		fmt.Printf("%v\n\n", domain.State)
+		// The guest finishes its shutdown
+		if domain.State == "in shutdown" {
+			domain.State = "shut off"
+		}
+	case args[0] == "domuuid":
+		fmt.Printf("%v\n\n", domain.Uuid)
+	case args[0] == "domifaddr":
+		domain.Queries++
+		fmt.Println(" Name       MAC address          Protocol     Address")
+		fmt.Println("-------------------------------------------------------------------------------")
+		if domain.State == "running" && domain.Queries > 1 {
+			fmt.Println(" vnet0      52:54:00:6b:3c:58    ipv4         192.168.122.10/24")
+		}
+		fmt.Println()
+	case args[0] == "start":
+		domain.State = "running"
+		domain.Queries = 0
+		fmt.Printf("Domain '%v' started\n\n", name)
+	case args[0] == "shutdown" && domain.State == "running":
+		domain.State = "in shutdown"
+		fmt.Printf("Domain '%v' is being shutdown\n\n", name)
+	case args[0] == "destroy" && domain.State != "shut off":
+		domain.State = "shut off"
+		fmt.Printf("Domain '%v' destroyed\n\n", name)
+	case args[0] == "shutdown" || args[0] == "destroy":
+		failed("Failed to %v domain '%v'", args[0], name)
+		return failed("Requested operation is not valid: domain is not running")
+	case args[0] == "undefine":
+		delete(domains, name)
+		fmt.Printf("Domain '%v' has been undefined\n\n", name)
+	default:
+		return failed("unknown command: '%v'", args[0])
+	}
+
+	return 0
+}
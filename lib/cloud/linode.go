@@ -0,0 +1,407 @@
+package cloud
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"start-my-game/lib/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// https://www.linode.com/docs/api/
+const linodeProvider string = "Linode"
+
+const linodeEndpoint = "https://api.linode.com/v4"
+
+// Deploying a private image to a new Linode takes much longer than booting it
+const linodeBootTimeout = 15 * time.Minute
+
+type LinodeCloud struct {
+	rest *restClient
+	// Images are identified by strings like 'private/1234'
+	ids *idMap
+}
+
+type linodePage struct {
+	Page  int `json:"page"`
+	Pages int `json:"pages"`
+}
+
+type linodeSshKey struct {
+	Id     int    `json:"id"`
+	Label  string `json:"label"`
+	SshKey string `json:"ssh_key"`
+}
+
+type linodeImage struct {
+	Id       string `json:"id"`
+	Label    string `json:"label"`
+	Created  string `json:"created"`
+	Status   string `json:"status"`
+	IsPublic bool   `json:"is_public"`
+}
+
+type linodeInstance struct {
+	Id     int      `json:"id"`
+	Label  string   `json:"label"`
+	Status string   `json:"status"`
+	Ipv4   []string `json:"ipv4"`
+}
+
+type linodeDisk struct {
+	Id         int    `json:"id"`
+	Filesystem string `json:"filesystem"`
+	Size       int    `json:"size"`
+}
+
+type linodePrice struct {
+	Hourly float64 `json:"hourly"`
+}
+
+type linodeType struct {
+	Price        linodePrice `json:"price"`
+	RegionPrices []struct {
+		Id string `json:"id"`
+		linodePrice
+	} `json:"region_prices"`
+}
+
+type linodeCreateInstance struct {
+	Region         string   `json:"region"`
+	Type           string   `json:"type"`
+	Label          string   `json:"label"`
+	Image          string   `json:"image"`
+	RootPass       string   `json:"root_pass"`
+	AuthorizedKeys []string `json:"authorized_keys,omitempty"`
+	Booted         bool     `json:"booted"`
+}
+
+func (cloud *LinodeCloud) GetProvider() string {
+	return linodeProvider
+}
+
+// Accepts the MD5 fingerprint or the label of the key
+func (cloud *LinodeCloud) GetSSHKey(fingerprint string) (int, error) {
+	var keys []linodeSshKey
+
+	err := cloud.list("/profile/sshkeys", func(path string) (linodePage, error) {
+		var response struct {
+			linodePage
+			Data []linodeSshKey `json:"data"`
+		}
+		err := cloud.rest.do("GET", path, nil, &response)
+		keys = append(keys, response.Data...)
+		return response.linodePage, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't list ssh keys: %v", err)
+	}
+
+	for _, key := range keys {
+		actual, err := keyFingerprint(key.SshKey)
+		if (err == nil && strings.EqualFold(actual, fingerprint)) || key.Label == fingerprint {
+			return key.Id, nil
+		}
+	}
+
+	return 0, newNotExistsError("ssh key", fingerprint, nil)
+}
+
+func (cloud *LinodeCloud) GetSnapshot(name string) (*Snapshot, error) {
+	snapshots, err := cloud.ListSnapshots(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) == 0 {
+		return nil, newNotExistsError("snapshot", name, nil)
+	}
+
+	return newestSnapshot(snapshots), nil
+}
+
+func (cloud *LinodeCloud) ListSnapshots(name string) ([]*Snapshot, error) {
+	var snapshots []*Snapshot
+
+	err := cloud.list("/images", func(path string) (linodePage, error) {
+		var response struct {
+			linodePage
+			Data []linodeImage `json:"data"`
+		}
+		err := cloud.rest.do("GET", path, nil, &response)
+
+		for _, image := range response.Data {
+			// Images which are still being created can't be deployed
+			if !image.IsPublic && image.Status == "available" && matchesSnapshot(image.Label, name) {
+				snapshots = append(snapshots, cloud.toLinodeSnapshot(image))
+			}
+		}
+
+		return response.linodePage, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list images: %v", err)
+	}
+
+	return snapshots, nil
+}
+
+func (cloud *LinodeCloud) DeleteSnapshot(snapshot *Snapshot) error {
+	id, ok := cloud.ids.get(snapshot.Id)
+	if !ok {
+		return newNotExistsError("snapshot", snapshot.Name, nil)
+	}
+
+	err := cloud.rest.do("DELETE", "/images/"+id, nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't delete image: %v", err)
+	}
+
+	return nil
+}
+
+// The region specific price is used, if the type has one for the region
+func (cloud *LinodeCloud) HourlyPrice(serverType string, region string) (float64, error) {
+	var response linodeType
+
+	err := cloud.rest.do("GET", "/linode/types/"+serverType, nil, &response)
+	if isRestStatus(err, 404) {
+		return 0, newNotExistsError("type", serverType, err)
+	} else if err != nil {
+		return 0, fmt.Errorf("couldn't get type: %v", err)
+	}
+
+	for _, price := range response.RegionPrices {
+		if strings.EqualFold(price.Id, region) {
+			return price.Hourly, nil
+		}
+	}
+
+	return response.Price.Hourly, nil
+}
+
+func (cloud *LinodeCloud) BootTimeout() time.Duration {
+	return linodeBootTimeout
+}
+
+func (cloud *LinodeCloud) GetServer(name string) (*Server, error) {
+	var found *linodeInstance
+
+	err := cloud.list("/linode/instances", func(path string) (linodePage, error) {
+		var response struct {
+			linodePage
+			Data []linodeInstance `json:"data"`
+		}
+		err := cloud.rest.do("GET", path, nil, &response)
+
+		for i, instance := range response.Data {
+			if strings.EqualFold(instance.Label, name) {
+				found = &response.Data[i]
+				// Stops the pagination
+				return linodePage{}, err
+			}
+		}
+
+		return response.linodePage, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list linodes: %v", err)
+	}
+
+	if found == nil {
+		return nil, newNotExistsError("server", name, nil)
+	}
+
+	return toLinodeServer(found), nil
+}
+
+func (cloud *LinodeCloud) StartServer(server *Server) error {
+	err := cloud.rest.do("POST", cloud.instancePath(server)+"/boot", nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't boot linode: %v", err)
+	}
+
+	return nil
+}
+
+func (cloud *LinodeCloud) StopServer(server *Server) error {
+	err := cloud.rest.do("POST", cloud.instancePath(server)+"/shutdown", nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't shutdown linode: %v", err)
+	}
+
+	return nil
+}
+
+func (cloud *LinodeCloud) CreateServer(options CreateOptions) (*Server, error) {
+	imageId, ok := cloud.ids.get(options.Snapshot.Id)
+	if !ok {
+		return nil, newNotExistsError("snapshot", options.Snapshot.Name, nil)
+	}
+
+	// Linode needs the public key instead of its id
+	var key linodeSshKey
+	err := cloud.rest.do("GET", "/profile/sshkeys/"+strconv.Itoa(options.SshKey), nil, &key)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get ssh key: %v", err)
+	}
+
+	// A root password is required, but only the ssh key is used to log in
+	rootPass, err := randomPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	request := linodeCreateInstance{
+		Region:         options.Region,
+		Type:           options.Machine,
+		Label:          options.Name,
+		Image:          imageId,
+		RootPass:       rootPass,
+		AuthorizedKeys: []string{key.SshKey},
+		Booted:         true,
+	}
+
+	var instance linodeInstance
+	err = cloud.rest.do("POST", "/linode/instances", request, &instance)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create linode: %v", err)
+	}
+
+	return toLinodeServer(&instance), nil
+}
+
+func (cloud *LinodeCloud) DestroyServer(server *Server) error {
+	err := cloud.rest.do("DELETE", cloud.instancePath(server), nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't delete the linode: %v", err)
+	}
+
+	return nil
+}
+
+// Creates an image of the largest disk of the server
+func (cloud *LinodeCloud) CreateSnapshot(server *Server, name string) (*Snapshot, error) {
+	var disks struct {
+		Data []linodeDisk `json:"data"`
+	}
+
+	err := cloud.rest.do("GET", cloud.instancePath(server)+"/disks", nil, &disks)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list disks: %v", err)
+	}
+
+	var disk *linodeDisk
+	for i, current := range disks.Data {
+		if current.Filesystem != "swap" && (disk == nil || current.Size > disk.Size) {
+			disk = &disks.Data[i]
+		}
+	}
+
+	if disk == nil {
+		return nil, fmt.Errorf("linode %v has no disk for an image", server.Name)
+	}
+
+	var image linodeImage
+	err = cloud.rest.do("POST", "/images", map[string]interface{}{
+		"disk_id": disk.Id,
+		"label":   name,
+	}, &image)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create image: %v", err)
+	}
+
+	// Polling the image until it's available
+	deadline := time.Now().Add(snapshotTimeout)
+	for image.Status != "available" {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("couldn't finish image of linode %v: timeout", server.Name)
+		}
+
+		time.Sleep(snapshotPollInterval)
+
+		err = cloud.rest.do("GET", "/images/"+image.Id, nil, &image)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get the image progress: %v", err)
+		}
+	}
+
+	return cloud.toLinodeSnapshot(image), nil
+}
+
+func (cloud *LinodeCloud) instancePath(server *Server) string {
+	return "/linode/instances/" + strconv.Itoa(server.Id)
+}
+
+// Calls the function with the path of every page, until the last page is reached
+func (cloud *LinodeCloud) list(path string, page func(path string) (linodePage, error)) error {
+	for number := 1; ; number++ {
+		current, err := page(path + "?page_size=500&page=" + strconv.Itoa(number))
+		if err != nil {
+			return err
+		}
+
+		if current.Page >= current.Pages {
+			return nil
+		}
+	}
+}
+
+func (cloud *LinodeCloud) toLinodeSnapshot(image linodeImage) *Snapshot {
+	// The dates are in UTC without a time zone, invalid dates are treated as the oldest ones
+	created, _ := time.Parse("2006-01-02T15:04:05", image.Created)
+
+	return &Snapshot{
+		Name:    image.Label,
+		Id:      cloud.ids.add(image.Id),
+		Created: created,
+	}
+}
+
+func toLinodeServer(instance *linodeInstance) *Server {
+	status := StatusOff
+
+	switch instance.Status {
+	case "provisioning", "booting", "rebooting":
+		status = StatusStartup
+	case "running":
+		status = StatusActive
+	}
+
+	ip := ""
+	if len(instance.Ipv4) > 0 {
+		ip = instance.Ipv4[0]
+	}
+
+	return &Server{
+		Name:     instance.Label,
+		Id:       instance.Id,
+		Ip:       ip,
+		Status:   status,
+		Provider: linodeProvider,
+	}
+}
+
+func randomPassword() (string, error) {
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", fmt.Errorf("couldn't generate a root password: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+func newLinodeCloud(cfg *config.Config) *LinodeCloud {
+	endpoint := cfg.Cloud.Endpoint
+	if endpoint == "" {
+		endpoint = linodeEndpoint
+	}
+
+	return &LinodeCloud{
+		rest: newRestClient(endpoint, cfg.Cloud.Token),
+		ids:  newIdMap(),
+	}
+}
@@ -0,0 +1,218 @@
+package cloud
+
+import (
+	"encoding/json"
+	"start-my-game/lib/config"
+	"strings"
+	"testing"
+)
+
+func newTestLinodeCloud(t *testing.T, responses map[string][]string) (*LinodeCloud, *recordedApi) {
+	api, url := newRecordedApi(t, responses)
+
+	cfg := &config.Config{}
+	cfg.Cloud.Endpoint = url
+	cfg.Cloud.Token = "token"
+
+	return newLinodeCloud(cfg), api
+}
+
+func TestLinodeListPages(t *testing.T) {
+	cloud, api := newTestLinodeCloud(t, map[string][]string{
+		"GET /linode/instances?page_size=500&page=1": {`{
+			"data": [{"id": 11, "label": "other", "status": "running", "ipv4": ["192.0.2.11"]}],
+			"page": 1, "pages": 3, "results": 3
+		}`},
+		"GET /linode/instances?page_size=500&page=2": {`{
+			"data": [{"id": 12, "label": "gmod", "status": "running", "ipv4": ["192.0.2.12"]}],
+			"page": 2, "pages": 3, "results": 3
+		}`},
+		"GET /linode/instances?page_size=500&page=3": {`{
+			"data": [{"id": 13, "label": "third", "status": "offline", "ipv4": ["192.0.2.13"]}],
+			"page": 3, "pages": 3, "results": 3
+		}`},
+	})
+
+	server, err := cloud.GetServer("gmod")
+	if err != nil {
+		t.Fatalf("GetServer failed: %v", err)
+	}
+
+	if server.Id != 12 || server.Ip != "192.0.2.12" {
+		t.Errorf("got server %+v, expected the one of the second page", server)
+	}
+
+	// The pagination stops at the page with the server
+	if requests := api.received(); len(requests) != 2 {
+		t.Errorf("expected 2 requests, got %v", requests)
+	}
+
+	_, err = cloud.GetServer("missing")
+	if !IsNotExistsError(err) {
+		t.Errorf("expected a not exists error, got %v", err)
+	}
+
+	if requests := api.received(); len(requests) != 5 {
+		t.Errorf("expected all 3 pages to be requested for a missing server, got %v", requests)
+	}
+}
+
+func TestLinodeGetSSHKey(t *testing.T) {
+	keys, _ := json.Marshal(map[string]interface{}{
+		"data": []linodeSshKey{
+			{Id: 1, Label: "laptop", SshKey: "ssh-ed25519 invalid"},
+			{Id: 2, Label: "server", SshKey: testPublicKey},
+		},
+		"page":  1,
+		"pages": 1,
+	})
+
+	cloud, _ := newTestLinodeCloud(t, map[string][]string{
+		"GET /profile/sshkeys?page_size=500&page=1": {string(keys)},
+	})
+
+	for _, test := range []struct {
+		fingerprint string
+		id          int
+	}{
+		{testKeyFingerprint, 2},
+		{strings.ToUpper(testKeyFingerprint), 2},
+		{"laptop", 1},
+	} {
+		id, err := cloud.GetSSHKey(test.fingerprint)
+		if err != nil {
+			t.Errorf("GetSSHKey(%v) failed: %v", test.fingerprint, err)
+		} else if id != test.id {
+			t.Errorf("GetSSHKey(%v) = %v, expected %v", test.fingerprint, id, test.id)
+		}
+	}
+
+	_, err := cloud.GetSSHKey("00:11:22:33:44:55:66:77:88:99:aa:bb:cc:dd:ee:ff")
+	if !IsNotExistsError(err) {
+		t.Errorf("expected a not exists error, got %v", err)
+	}
+}
+
+func TestLinodeListSnapshots(t *testing.T) {
+	cloud, _ := newTestLinodeCloud(t, map[string][]string{
+		"GET /images?page_size=500&page=1": {`{
+			"data": [
+				{"id": "linode/debian10", "label": "gmod", "created": "2019-07-01T00:00:00", "status": "available", "is_public": true},
+				{"id": "private/1", "label": "gmod", "created": "2020-01-01T10:00:00", "status": "available", "is_public": false},
+				{"id": "private/2", "label": "gmod 2020-02-01 10:00", "created": "2020-02-01T10:00:00", "status": "available", "is_public": false}
+			],
+			"page": 1, "pages": 2, "results": 6
+		}`},
+		"GET /images?page_size=500&page=2": {`{
+			"data": [
+				{"id": "private/3", "label": "gmod 2020-03-01 10:00", "created": "2020-03-01T10:00:00", "status": "creating", "is_public": false},
+				{"id": "private/4", "label": "gmod-old", "created": "2019-01-01T10:00:00", "status": "available", "is_public": false},
+				{"id": "private/5", "label": "minecraft", "created": "2020-01-01T10:00:00", "status": "available", "is_public": false}
+			],
+			"page": 2, "pages": 2, "results": 6
+		}`},
+	})
+
+	snapshots, err := cloud.ListSnapshots("gmod")
+	if err != nil {
+		t.Fatalf("ListSnapshots failed: %v", err)
+	}
+
+	var names []string
+	for _, snapshot := range snapshots {
+		names = append(names, snapshot.Name)
+	}
+
+	if strings.Join(names, ",") != "gmod,gmod 2020-02-01 10:00" {
+		t.Errorf("got snapshots %v, expected only the available private images with the name", names)
+	}
+
+	newest, err := cloud.GetSnapshot("gmod")
+	if err != nil {
+		t.Fatalf("GetSnapshot failed: %v", err)
+	}
+
+	id, _ := cloud.ids.get(newest.Id)
+	if id != "private/2" {
+		t.Errorf("got the newest snapshot %v, expected private/2", id)
+	}
+}
+
+func TestLinodeCreateSnapshot(t *testing.T) {
+	fastSnapshotPolling(t)
+
+	cloud, api := newTestLinodeCloud(t, map[string][]string{
+		"GET /linode/instances/12/disks": {`{
+			"data": [
+				{"id": 101, "label": "Debian 10 Disk", "filesystem": "ext4", "size": 25088},
+				{"id": 102, "label": "512 MB Swap Image", "filesystem": "swap", "size": 51200},
+				{"id": 103, "label": "Data", "filesystem": "ext4", "size": 1024}
+			],
+			"page": 1, "pages": 1, "results": 3
+		}`},
+		"POST /images": {`{"id": "private/7", "label": "gmod 2020-01-02 15:04", "created": "2020-01-02T15:04:00", "status": "creating", "is_public": false}`},
+		"GET /images/private/7": {
+			`{"id": "private/7", "label": "gmod 2020-01-02 15:04", "created": "2020-01-02T15:04:00", "status": "creating", "is_public": false}`,
+			`{"id": "private/7", "label": "gmod 2020-01-02 15:04", "created": "2020-01-02T15:04:00", "status": "available", "is_public": false}`,
+		},
+	})
+
+	snapshot, err := cloud.CreateSnapshot(&Server{Id: 12, Name: "gmod"}, "gmod 2020-01-02 15:04")
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	if id, _ := cloud.ids.get(snapshot.Id); id != "private/7" || snapshot.Name != "gmod 2020-01-02 15:04" {
+		t.Errorf("got snapshot %+v (%v), expected private/7", snapshot, id)
+	}
+
+	var request struct {
+		DiskId int    `json:"disk_id"`
+		Label  string `json:"label"`
+	}
+	_ = json.Unmarshal([]byte(api.body("POST /images")), &request)
+	if request.DiskId != 101 || request.Label != "gmod 2020-01-02 15:04" {
+		t.Errorf("got image request %+v, expected the largest disk which isn't swap", request)
+	}
+
+	polls := 0
+	for _, request := range api.received() {
+		if request == "GET /images/private/7" {
+			polls++
+		}
+	}
+	if polls != 2 {
+		t.Errorf("expected 2 polls until the image is available, got %v", polls)
+	}
+}
+
+func TestToLinodeServer(t *testing.T) {
+	for _, test := range []struct {
+		status   string
+		expected string
+	}{
+		{"provisioning", StatusStartup},
+		{"booting", StatusStartup},
+		{"rebooting", StatusStartup},
+		{"running", StatusActive},
+		{"shutting_down", StatusOff},
+		{"offline", StatusOff},
+		{"migrating", StatusOff},
+	} {
+		server := toLinodeServer(&linodeInstance{Id: 1, Label: "gmod", Status: test.status})
+		if server.Status != test.expected {
+			t.Errorf("status %v is mapped to %v, expected %v", test.status, server.Status, test.expected)
+		}
+	}
+
+	server := toLinodeServer(&linodeInstance{Id: 1, Label: "gmod", Status: "running",
+		Ipv4: []string{"192.0.2.1", "192.168.1.1"}})
+	if server.Ip != "192.0.2.1" || server.Provider != linodeProvider {
+		t.Errorf("got server %+v, expected the first ip", server)
+	}
+
+	server = toLinodeServer(&linodeInstance{Id: 1, Label: "gmod", Status: "provisioning"})
+	if server.Ip != "" {
+		t.Errorf("got ip %v for a linode without an address", server.Ip)
+	}
+}
@@ -0,0 +1,130 @@
+package cloud
+
+import (
+	"start-my-game/lib/metrics"
+	"time"
+)
+
+var (
+	callDuration = metrics.NewHistogram("smg_cloud_request_duration_seconds",
+		"Duration of the cloud API calls", metrics.DurationBuckets, "provider", "method")
+	callErrors = metrics.NewCounter("smg_cloud_request_errors_total",
+		"Failed cloud API calls, a missing server or snapshot isn't counted", "provider", "method")
+)
+
+// Records the duration and errors of every call to the wrapped cloud
+type instrumentedCloud struct {
+	cloud    Cloud
+	provider string
+}
+
+func (instrumented *instrumentedCloud) observe(method string, start time.Time, err error) {
+	callDuration.Observe(time.Since(start).Seconds(), instrumented.provider, method)
+	if err != nil && !IsNotExistsError(err) {
+		callErrors.Inc(instrumented.provider, method)
+	}
+}
+
+func (instrumented *instrumentedCloud) GetProvider() string {
+	return instrumented.provider
+}
+
+func (instrumented *instrumentedCloud) GetSSHKey(fingerprint string) (int, error) {
+	start := time.Now()
+	id, err := instrumented.cloud.GetSSHKey(fingerprint)
+	instrumented.observe("GetSSHKey", start, err)
+	return id, err
+}
+
+func (instrumented *instrumentedCloud) GetSnapshot(name string) (*Snapshot, error) {
+	start := time.Now()
+	snapshot, err := instrumented.cloud.GetSnapshot(name)
+	instrumented.observe("GetSnapshot", start, err)
+	return snapshot, err
+}
+
+func (instrumented *instrumentedCloud) ListSnapshots(name string) ([]*Snapshot, error) {
+	start := time.Now()
+	snapshots, err := instrumented.cloud.ListSnapshots(name)
+	instrumented.observe("ListSnapshots", start, err)
+	return snapshots, err
+}
+
+func (instrumented *instrumentedCloud) DeleteSnapshot(snapshot *Snapshot) error {
+	start := time.Now()
+	err := instrumented.cloud.DeleteSnapshot(snapshot)
+	instrumented.observe("DeleteSnapshot", start, err)
+	return err
+}
+
+func (instrumented *instrumentedCloud) GetServer(name string) (*Server, error) {
+	start := time.Now()
+	server, err := instrumented.cloud.GetServer(name)
+	instrumented.observe("GetServer", start, err)
+	return server, err
+}
+
+func (instrumented *instrumentedCloud) StartServer(server *Server) error {
+	start := time.Now()
+	err := instrumented.cloud.StartServer(server)
+	instrumented.observe("StartServer", start, err)
+	return err
+}
+
+func (instrumented *instrumentedCloud) StopServer(server *Server) error {
+	start := time.Now()
+	err := instrumented.cloud.StopServer(server)
+	instrumented.observe("StopServer", start, err)
+	return err
+}
+
+func (instrumented *instrumentedCloud) CreateServer(options CreateOptions) (*Server, error) {
+	start := time.Now()
+	server, err := instrumented.cloud.CreateServer(options)
+	instrumented.observe("CreateServer", start, err)
+	return server, err
+}
+
+func (instrumented *instrumentedCloud) DestroyServer(server *Server) error {
+	start := time.Now()
+	err := instrumented.cloud.DestroyServer(server)
+	instrumented.observe("DestroyServer", start, err)
+	return err
+}
+
+func (instrumented *instrumentedCloud) CreateSnapshot(server *Server, name string) (*Snapshot, error) {
+	start := time.Now()
+	snapshot, err := instrumented.cloud.CreateSnapshot(server, name)
+	instrumented.observe("CreateSnapshot", start, err)
+	return snapshot, err
+}
+
+func (instrumented *instrumentedCloud) BootTimeout() time.Duration {
+	return BootTimeout(instrumented.cloud)
+}
+
+// Only used for clouds which provide prices, otherwise every cloud would look like a PriceProvider
+type instrumentedPriceCloud struct {
+	*instrumentedCloud
+	prices PriceProvider
+}
+
+func (instrumented *instrumentedPriceCloud) HourlyPrice(serverType string, region string) (float64, error) {
+	start := time.Now()
+	price, err := instrumented.prices.HourlyPrice(serverType, region)
+	instrumented.observe("HourlyPrice", start, err)
+	return price, err
+}
+
+func instrument(cloud Cloud) Cloud {
+	instrumented := &instrumentedCloud{
+		cloud:    cloud,
+		provider: cloud.GetProvider(),
+	}
+
+	if prices, ok := cloud.(PriceProvider); ok {
+		return &instrumentedPriceCloud{instrumentedCloud: instrumented, prices: prices}
+	}
+
+	return instrumented
+}
@@ -0,0 +1,261 @@
+package cloud
+
+import (
+	"fmt"
+	"net"
+	"start-my-game/lib/config"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Wakes a dedicated machine with Wake-on-LAN and shuts it down over SSH. A powered off machine
+// is reported as not existing, so the manager "creates" the server by waking the machine and
+// "destroys" it after the shutdown. There are no snapshots, the machine keeps its disk.
+const physicalProvider string = "Physical"
+
+const physicalBroadcast = "255.255.255.255:9"
+
+const physicalShutdownCommand = "sudo poweroff"
+
+const physicalBootTime = 300
+
+// Timeout of a single reachability check
+const reachableTimeout = 3 * time.Second
+
+// Maximum duration to wait for the machine to become unreachable after the shutdown command
+const physicalShutdownTimeout = 3 * time.Minute
+
+type PhysicalCloud struct {
+	mac             net.HardwareAddr
+	broadcast       string
+	ip              string
+	checkPort       int
+	sshPort         int
+	sshUser         string
+	sshKeyFile      string
+	shutdownCommand string
+	bootTime        time.Duration
+
+	mutex sync.Mutex
+	// The machine is starting until it's reachable or the boot time passed
+	wokeAt time.Time
+}
+
+func (cloud *PhysicalCloud) GetProvider() string {
+	return physicalProvider
+}
+
+// The machine already has the ssh key
+func (cloud *PhysicalCloud) GetSSHKey(fingerprint string) (int, error) {
+	return 0, nil
+}
+
+// Returns a placeholder, because the machine keeps its disk
+func (cloud *PhysicalCloud) GetSnapshot(name string) (*Snapshot, error) {
+	return &Snapshot{Name: name}, nil
+}
+
+func (cloud *PhysicalCloud) ListSnapshots(name string) ([]*Snapshot, error) {
+	return []*Snapshot{{Name: name}}, nil
+}
+
+func (cloud *PhysicalCloud) DeleteSnapshot(snapshot *Snapshot) error {
+	return nil
+}
+
+func (cloud *PhysicalCloud) BootTimeout() time.Duration {
+	return cloud.bootTime
+}
+
+func (cloud *PhysicalCloud) GetServer(name string) (*Server, error) {
+	status := StatusActive
+
+	if !cloud.reachable() {
+		cloud.mutex.Lock()
+		waking := !cloud.wokeAt.IsZero() && time.Since(cloud.wokeAt) < cloud.bootTime
+		cloud.mutex.Unlock()
+
+		if !waking {
+			return nil, newNotExistsError("server", name, nil)
+		}
+		status = StatusStartup
+	}
+
+	return &Server{
+		Name:     name,
+		Id:       1,
+		Ip:       cloud.ip,
+		Status:   status,
+		Provider: physicalProvider,
+	}, nil
+}
+
+func (cloud *PhysicalCloud) StartServer(server *Server) error {
+	return cloud.wake()
+}
+
+// Runs the shutdown command over SSH
+func (cloud *PhysicalCloud) StopServer(server *Server) error {
+	args := []string{
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=10",
+		"-p", strconv.Itoa(cloud.sshPort),
+	}
+	if cloud.sshKeyFile != "" {
+		args = append(args, "-i", cloud.sshKeyFile)
+	}
+
+	destination := cloud.ip
+	if cloud.sshUser != "" {
+		destination = cloud.sshUser + "@" + cloud.ip
+	}
+	args = append(args, destination, cloud.shutdownCommand)
+
+	_, err := run(time.Minute, "ssh", args...)
+	// The connection may be closed by the shutdown before the command returns
+	if err != nil && !cloud.waitOffline(30*time.Second) {
+		return fmt.Errorf("couldn't run the shutdown command: %v", err)
+	}
+
+	return nil
+}
+
+// Wakes the machine, the options are ignored
+func (cloud *PhysicalCloud) CreateServer(options CreateOptions) (*Server, error) {
+	err := cloud.wake()
+	if err != nil {
+		return nil, err
+	}
+
+	return cloud.GetServer(options.Name)
+}
+
+// Shuts down the machine, if it's still online, and waits until it's powered off
+func (cloud *PhysicalCloud) DestroyServer(server *Server) error {
+	cloud.mutex.Lock()
+	cloud.wokeAt = time.Time{}
+	cloud.mutex.Unlock()
+
+	if cloud.reachable() {
+		err := cloud.StopServer(server)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !cloud.waitOffline(physicalShutdownTimeout) {
+		return fmt.Errorf("machine %v is still online after %v", server.Name, physicalShutdownTimeout)
+	}
+
+	return nil
+}
+
+// Returns a placeholder, because the machine keeps its disk
+func (cloud *PhysicalCloud) CreateSnapshot(server *Server, name string) (*Snapshot, error) {
+	return &Snapshot{Name: name, Created: time.Now()}, nil
+}
+
+// Sends the Wake-on-LAN magic packet, which is six times 0xFF followed by the MAC address 16 times
+func (cloud *PhysicalCloud) wake() error {
+	packet := make([]byte, 0, 6+16*len(cloud.mac))
+	for i := 0; i < 6; i++ {
+		packet = append(packet, 0xFF)
+	}
+	for i := 0; i < 16; i++ {
+		packet = append(packet, cloud.mac...)
+	}
+
+	conn, err := net.Dial("udp", cloud.broadcast)
+	if err != nil {
+		return fmt.Errorf("couldn't send the wake-on-lan packet: %v", err)
+	}
+	defer conn.Close()
+
+	// UDP packets can get lost, sending a few of them doesn't hurt
+	for i := 0; i < 3; i++ {
+		_, err = conn.Write(packet)
+		if err != nil {
+			return fmt.Errorf("couldn't send the wake-on-lan packet: %v", err)
+		}
+	}
+
+	cloud.mutex.Lock()
+	cloud.wokeAt = time.Now()
+	cloud.mutex.Unlock()
+
+	return nil
+}
+
+// Returns false if the machine is still reachable after the timeout
+func (cloud *PhysicalCloud) waitOffline(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for cloud.reachable() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	return true
+}
+
+// Whether the machine accepts TCP connections on the check port
+func (cloud *PhysicalCloud) reachable() bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(cloud.ip, strconv.Itoa(cloud.checkPort)), reachableTimeout)
+	if err != nil {
+		return false
+	}
+
+	_ = conn.Close()
+	return true
+}
+
+func newPhysicalCloud(cfg *config.Config) (*PhysicalCloud, error) {
+	physicalCfg := config.Physical{}
+	if cfg.Cloud.Physical != nil {
+		physicalCfg = *cfg.Cloud.Physical
+	}
+
+	mac, err := net.ParseMAC(physicalCfg.Mac)
+	if err != nil {
+		return nil, fmt.Errorf("the physical provider needs a valid mac address: %v", err)
+	}
+
+	if physicalCfg.Ip == "" {
+		return nil, fmt.Errorf("the physical provider needs the ip of the machine")
+	}
+
+	cloud := &PhysicalCloud{
+		mac:             mac,
+		broadcast:       physicalCfg.Broadcast,
+		ip:              physicalCfg.Ip,
+		checkPort:       physicalCfg.CheckPort,
+		sshPort:         physicalCfg.SshPort,
+		sshUser:         physicalCfg.SshUser,
+		sshKeyFile:      physicalCfg.SshKeyFile,
+		shutdownCommand: physicalCfg.ShutdownCommand,
+		bootTime:        time.Duration(physicalCfg.BootTime) * time.Second,
+	}
+
+	if cloud.broadcast == "" {
+		cloud.broadcast = physicalBroadcast
+	} else if _, _, err := net.SplitHostPort(cloud.broadcast); err != nil {
+		// The discard port is commonly used for Wake-on-LAN
+		cloud.broadcast = net.JoinHostPort(cloud.broadcast, "9")
+	}
+	if cloud.sshPort == 0 {
+		cloud.sshPort = 22
+	}
+	if cloud.checkPort == 0 {
+		cloud.checkPort = cloud.sshPort
+	}
+	if cloud.shutdownCommand == "" {
+		cloud.shutdownCommand = physicalShutdownCommand
+	}
+	if cloud.bootTime <= 0 {
+		cloud.bootTime = physicalBootTime * time.Second
+	}
+
+	return cloud, nil
+}
@@ -0,0 +1,150 @@
+package cloud
+
+import (
+	"bytes"
+	"net"
+	"start-my-game/lib/config"
+	"testing"
+	"time"
+)
+
+// Creates a cloud which sends the magic packets to the returned UDP listener. The machine is
+// reachable as long as the returned TCP listener is open.
+func newTestPhysicalCloud(t *testing.T) (*PhysicalCloud, net.PacketConn, net.Listener) {
+	packets, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen for the magic packets: %v", err)
+	}
+	t.Cleanup(func() { _ = packets.Close() })
+
+	machine, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen for the reachability checks: %v", err)
+	}
+	t.Cleanup(func() { _ = machine.Close() })
+
+	cfg := &config.Config{}
+	cfg.Cloud.Physical = &config.Physical{
+		Mac:       "52:54:00:6b:3c:58",
+		Ip:        "127.0.0.1",
+		Broadcast: packets.LocalAddr().String(),
+		CheckPort: machine.Addr().(*net.TCPAddr).Port,
+	}
+
+	cloud, err := newPhysicalCloud(cfg)
+	if err != nil {
+		t.Fatalf("couldn't create the cloud: %v", err)
+	}
+
+	return cloud, packets, machine
+}
+
+func TestPhysicalMagicPacket(t *testing.T) {
+	cloud, packets, _ := newTestPhysicalCloud(t)
+
+	err := cloud.wake()
+	if err != nil {
+		t.Fatalf("wake failed: %v", err)
+	}
+
+	expected := bytes.Repeat([]byte{0xFF}, 6)
+	for i := 0; i < 16; i++ {
+		expected = append(expected, 0x52, 0x54, 0x00, 0x6b, 0x3c, 0x58)
+	}
+
+	_ = packets.SetReadDeadline(time.Now().Add(5 * time.Second))
+	packet := make([]byte, 1024)
+	n, _, err := packets.ReadFrom(packet)
+	if err != nil {
+		t.Fatalf("didn't receive the magic packet: %v", err)
+	}
+
+	if !bytes.Equal(packet[:n], expected) {
+		t.Errorf("got packet %x, expected %x", packet[:n], expected)
+	}
+}
+
+func TestPhysicalGetServer(t *testing.T) {
+	cloud, _, machine := newTestPhysicalCloud(t)
+
+	server, err := cloud.GetServer("gmod")
+	if err != nil {
+		t.Fatalf("GetServer failed: %v", err)
+	}
+	if server.Status != StatusActive || server.Ip != "127.0.0.1" {
+		t.Errorf("got server %+v, expected the reachable machine to be active", server)
+	}
+
+	// Connections to the closed port are refused right away
+	_ = machine.Close()
+
+	_, err = cloud.GetServer("gmod")
+	if !IsNotExistsError(err) {
+		t.Errorf("expected a not exists error for the unreachable machine, got %v", err)
+	}
+
+	server, err = cloud.CreateServer(CreateOptions{Name: "gmod"})
+	if err != nil {
+		t.Fatalf("CreateServer failed: %v", err)
+	}
+	if server.Status != StatusStartup {
+		t.Errorf("got status %v for the waking machine, expected %v", server.Status, StatusStartup)
+	}
+
+	server, err = cloud.GetServer("gmod")
+	if err != nil || server.Status != StatusStartup {
+		t.Errorf("got server %+v and error %v, expected the machine to be still waking", server, err)
+	}
+
+	// The machine didn't come up within the boot time
+	cloud.bootTime = 0
+
+	_, err = cloud.GetServer("gmod")
+	if !IsNotExistsError(err) {
+		t.Errorf("expected a not exists error after the boot time, got %v", err)
+	}
+}
+
+func TestPhysicalPlaceholderSnapshots(t *testing.T) {
+	cloud, _, _ := newTestPhysicalCloud(t)
+
+	snapshot, err := cloud.GetSnapshot("gmod")
+	if err != nil || snapshot.Name != "gmod" {
+		t.Errorf("got snapshot %+v and error %v, expected a placeholder named gmod", snapshot, err)
+	}
+
+	snapshots, err := cloud.ListSnapshots("gmod")
+	if err != nil || len(snapshots) != 1 || snapshots[0].Name != "gmod" {
+		t.Errorf("got snapshots %+v and error %v, expected a single placeholder", snapshots, err)
+	}
+
+	snapshot, err = cloud.CreateSnapshot(&Server{Name: "gmod"}, "gmod 2020-01-02 15:04")
+	if err != nil || snapshot.Name != "gmod 2020-01-02 15:04" || snapshot.Created.IsZero() {
+		t.Errorf("got snapshot %+v and error %v, expected a placeholder with the new name", snapshot, err)
+	}
+
+	err = cloud.DeleteSnapshot(snapshot)
+	if err != nil {
+		t.Errorf("DeleteSnapshot failed: %v", err)
+	}
+}
+
+func TestPhysicalDefaults(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Cloud.Physical = &config.Physical{Mac: "52:54:00:6b:3c:58", Ip: "192.168.1.20", Broadcast: "192.168.1.255"}
+
+	cloud, err := newPhysicalCloud(cfg)
+	if err != nil {
+		t.Fatalf("couldn't create the cloud: %v", err)
+	}
+
+	if cloud.broadcast != "192.168.1.255:9" || cloud.checkPort != 22 || cloud.bootTime != physicalBootTime*time.Second {
+		t.Errorf("got broadcast %v, check port %v and boot time %v", cloud.broadcast, cloud.checkPort, cloud.bootTime)
+	}
+
+	cfg.Cloud.Physical.Mac = "invalid"
+	_, err = newPhysicalCloud(cfg)
+	if err == nil {
+		t.Errorf("expected an error for an invalid mac address")
+	}
+}
@@ -0,0 +1,19 @@
+package cloud
+
+import "fmt"
+
+// Implemented by clouds, which can tell the price of their server types
+type PriceProvider interface {
+	// The price per hour including taxes in the currency of the account
+	HourlyPrice(serverType string, region string) (float64, error)
+}
+
+// Returns an error if the cloud doesn't provide prices
+func HourlyPrice(cloud Cloud, serverType string, region string) (float64, error) {
+	provider, ok := cloud.(PriceProvider)
+	if !ok {
+		return 0, fmt.Errorf("the provider %v doesn't provide prices", cloud.GetProvider())
+	}
+
+	return provider.HourlyPrice(serverType, region)
+}
@@ -0,0 +1,116 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A minimal JSON client for providers without an official Go library
+type restClient struct {
+	baseUrl string
+	token   string
+	client  *http.Client
+}
+
+// The response of a failed API call
+type restError struct {
+	method string
+	path   string
+	status int
+	body   string
+}
+
+func (err *restError) Error() string {
+	return fmt.Sprintf("%v %v responded with %v: %v", err.method, err.path, err.status, err.body)
+}
+
+func isRestStatus(err error, status int) bool {
+	restErr, ok := err.(*restError)
+	return ok && restErr.status == status
+}
+
+// Sends the request body as JSON and decodes the response into the result, if it's not nil
+func (rest *restClient) do(method string, path string, body interface{}, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("couldn't compose request: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	request, err := http.NewRequest(method, rest.baseUrl+path, reader)
+	if err != nil {
+		return fmt.Errorf("couldn't create request: %v", err)
+	}
+
+	if rest.token != "" {
+		request.Header.Set("Authorization", "Bearer "+rest.token)
+	}
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := rest.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("couldn't reach the api: %v", err)
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("couldn't read response: %v", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return &restError{
+			method: method,
+			path:   path,
+			status: response.StatusCode,
+			body:   strings.TrimSpace(string(content)),
+		}
+	}
+
+	if result == nil || len(content) == 0 {
+		return nil
+	}
+
+	err = json.Unmarshal(content, result)
+	if err != nil {
+		return fmt.Errorf("couldn't decode response of %v %v: %v", method, path, err)
+	}
+
+	return nil
+}
+
+func newRestClient(baseUrl string, token string) *restClient {
+	return &restClient{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Sends all requests to the unix socket, the host of the base url is ignored
+func newUnixRestClient(baseUrl string, socket string) *restClient {
+	rest := newRestClient(baseUrl, "")
+	rest.client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+
+	return rest
+}
@@ -0,0 +1,102 @@
+package cloud
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// An ed25519 key and its MD5 fingerprint, which the providers use to look up the key
+const testPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIFJFkIGIfy9aefv08/Y7KB8bd6WMI40wp4aZN0l++z2O test"
+
+const testKeyFingerprint = "a0:83:be:8a:5c:38:22:36:b7:11:96:0f:d1:63:23:9c"
+
+// Replays recorded API responses. The responses are keyed by the method and the request URI like
+// 'GET /images?page=1', the last response of a key is repeated.
+type recordedApi struct {
+	t         *testing.T
+	mutex     sync.Mutex
+	responses map[string][]string
+	// The status codes of the keys, which don't respond with 200
+	statuses map[string]int
+	requests []string
+	bodies   map[string]string
+}
+
+func (api *recordedApi) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	key := request.Method + " " + request.URL.RequestURI()
+	body, _ := ioutil.ReadAll(request.Body)
+
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	api.requests = append(api.requests, key)
+	api.bodies[key] = string(body)
+
+	responses := api.responses[key]
+	if len(responses) == 0 {
+		api.t.Logf("no recorded response for %v", key)
+		writer.WriteHeader(http.StatusNotFound)
+		_, _ = writer.Write([]byte(`{"errors":[{"reason":"Not found"}]}`))
+		return
+	}
+
+	if len(responses) > 1 {
+		api.responses[key] = responses[1:]
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	if status, ok := api.statuses[key]; ok {
+		writer.WriteHeader(status)
+	}
+	_, _ = writer.Write([]byte(responses[0]))
+}
+
+// The requests in the order they were received
+func (api *recordedApi) received() []string {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	return append([]string(nil), api.requests...)
+}
+
+// Responds with the status code instead of 200 to the requests with the key
+func (api *recordedApi) respondWith(key string, status int) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	api.statuses[key] = status
+}
+
+// The body of the last request with the key
+func (api *recordedApi) body(key string) string {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	return api.bodies[key]
+}
+
+// Starts a server replaying the responses, which is closed after the test
+func newRecordedApi(t *testing.T, responses map[string][]string) (*recordedApi, string) {
+	api := &recordedApi{
+		t:         t,
+		responses: responses,
+		statuses:  make(map[string]int),
+		bodies:    make(map[string]string),
+	}
+
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	return api, server.URL
+}
+
+// Shortens the polling of snapshots until the end of the test
+func fastSnapshotPolling(t *testing.T) {
+	previous := snapshotPollInterval
+	snapshotPollInterval = time.Millisecond
+	t.Cleanup(func() { snapshotPollInterval = previous })
+}
@@ -0,0 +1,377 @@
+package cloud
+
+import (
+	"fmt"
+	"net/url"
+	"start-my-game/lib/config"
+	"strings"
+	"time"
+)
+
+// https://www.vultr.com/api/
+const vultrProvider string = "Vultr"
+
+const vultrEndpoint = "https://api.vultr.com/v2"
+
+type VultrCloud struct {
+	rest *restClient
+	// Vultr uses UUIDs for everything
+	ids *idMap
+}
+
+type vultrMeta struct {
+	Links struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+type vultrSshKey struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	SshKey string `json:"ssh_key"`
+}
+
+type vultrSnapshot struct {
+	Id          string `json:"id"`
+	DateCreated string `json:"date_created"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+}
+
+type vultrInstance struct {
+	Id           string `json:"id"`
+	Label        string `json:"label"`
+	MainIp       string `json:"main_ip"`
+	Status       string `json:"status"`
+	PowerStatus  string `json:"power_status"`
+	ServerStatus string `json:"server_status"`
+}
+
+type vultrCreateInstance struct {
+	Region     string   `json:"region"`
+	Plan       string   `json:"plan"`
+	SnapshotId string   `json:"snapshot_id"`
+	Label      string   `json:"label"`
+	Hostname   string   `json:"hostname"`
+	SshKeyIds  []string `json:"sshkey_id,omitempty"`
+}
+
+func (cloud *VultrCloud) GetProvider() string {
+	return vultrProvider
+}
+
+// Accepts the MD5 fingerprint or the name of the key
+func (cloud *VultrCloud) GetSSHKey(fingerprint string) (int, error) {
+	var keys []vultrSshKey
+
+	err := cloud.list(func(cursor string) (string, error) {
+		var response struct {
+			SshKeys []vultrSshKey `json:"ssh_keys"`
+			Meta    vultrMeta     `json:"meta"`
+		}
+		err := cloud.rest.do("GET", "/ssh-keys"+cursor, nil, &response)
+		keys = append(keys, response.SshKeys...)
+		return response.Meta.Links.Next, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't list ssh keys: %v", err)
+	}
+
+	for _, key := range keys {
+		actual, err := keyFingerprint(key.SshKey)
+		if (err == nil && strings.EqualFold(actual, fingerprint)) || key.Name == fingerprint {
+			return cloud.ids.add(key.Id), nil
+		}
+	}
+
+	return 0, newNotExistsError("ssh key", fingerprint, nil)
+}
+
+func (cloud *VultrCloud) GetSnapshot(name string) (*Snapshot, error) {
+	snapshots, err := cloud.ListSnapshots(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) == 0 {
+		return nil, newNotExistsError("snapshot", name, nil)
+	}
+
+	return newestSnapshot(snapshots), nil
+}
+
+func (cloud *VultrCloud) ListSnapshots(name string) ([]*Snapshot, error) {
+	var snapshots []*Snapshot
+
+	err := cloud.list(func(cursor string) (string, error) {
+		var response struct {
+			Snapshots []vultrSnapshot `json:"snapshots"`
+			Meta      vultrMeta       `json:"meta"`
+		}
+		err := cloud.rest.do("GET", "/snapshots"+cursor, nil, &response)
+
+		for _, snapshot := range response.Snapshots {
+			// Snapshots which are still being taken can't be used
+			if snapshot.Status == "complete" && matchesSnapshot(snapshot.Description, name) {
+				snapshots = append(snapshots, cloud.toVultrSnapshot(snapshot))
+			}
+		}
+
+		return response.Meta.Links.Next, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list snapshots: %v", err)
+	}
+
+	return snapshots, nil
+}
+
+func (cloud *VultrCloud) DeleteSnapshot(snapshot *Snapshot) error {
+	id, ok := cloud.ids.get(snapshot.Id)
+	if !ok {
+		return newNotExistsError("snapshot", snapshot.Name, nil)
+	}
+
+	err := cloud.rest.do("DELETE", "/snapshots/"+id, nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't delete snapshot: %v", err)
+	}
+
+	return nil
+}
+
+func (cloud *VultrCloud) GetServer(name string) (*Server, error) {
+	instance, err := cloud.findInstance(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return cloud.toVultrServer(instance), nil
+}
+
+func (cloud *VultrCloud) StartServer(server *Server) error {
+	id, err := cloud.instanceId(server)
+	if err != nil {
+		return err
+	}
+
+	err = cloud.rest.do("POST", "/instances/"+id+"/start", nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't power on instance: %v", err)
+	}
+
+	return nil
+}
+
+func (cloud *VultrCloud) StopServer(server *Server) error {
+	id, err := cloud.instanceId(server)
+	if err != nil {
+		return err
+	}
+
+	err = cloud.rest.do("POST", "/instances/"+id+"/halt", nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't halt instance: %v", err)
+	}
+
+	return nil
+}
+
+func (cloud *VultrCloud) CreateServer(options CreateOptions) (*Server, error) {
+	snapshotId, ok := cloud.ids.get(options.Snapshot.Id)
+	if !ok {
+		return nil, newNotExistsError("snapshot", options.Snapshot.Name, nil)
+	}
+
+	request := vultrCreateInstance{
+		Region:     options.Region,
+		Plan:       options.Machine,
+		SnapshotId: snapshotId,
+		Label:      options.Name,
+		Hostname:   options.Name,
+	}
+
+	if keyId, ok := cloud.ids.get(options.SshKey); ok {
+		request.SshKeyIds = []string{keyId}
+	}
+
+	var response struct {
+		Instance vultrInstance `json:"instance"`
+	}
+
+	err := cloud.rest.do("POST", "/instances", request, &response)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create instance: %v", err)
+	}
+
+	return cloud.toVultrServer(&response.Instance), nil
+}
+
+func (cloud *VultrCloud) DestroyServer(server *Server) error {
+	id, err := cloud.instanceId(server)
+	if err != nil {
+		return err
+	}
+
+	err = cloud.rest.do("DELETE", "/instances/"+id, nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't delete the instance: %v", err)
+	}
+
+	return nil
+}
+
+func (cloud *VultrCloud) CreateSnapshot(server *Server, name string) (*Snapshot, error) {
+	id, err := cloud.instanceId(server)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Snapshot vultrSnapshot `json:"snapshot"`
+	}
+
+	err = cloud.rest.do("POST", "/snapshots", map[string]string{
+		"instance_id": id,
+		"description": name,
+	}, &response)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create snapshot: %v", err)
+	}
+
+	// Polling the snapshot until it's complete
+	deadline := time.Now().Add(snapshotTimeout)
+	snapshot := response.Snapshot
+	for snapshot.Status != "complete" {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("couldn't finish snapshot of instance %v: timeout", server.Name)
+		}
+
+		time.Sleep(snapshotPollInterval)
+
+		err = cloud.rest.do("GET", "/snapshots/"+snapshot.Id, nil, &response)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get the snapshot progress: %v", err)
+		}
+		snapshot = response.Snapshot
+	}
+
+	return cloud.toVultrSnapshot(snapshot), nil
+}
+
+// Returns the instance with the label or a notExistsError
+func (cloud *VultrCloud) findInstance(name string) (*vultrInstance, error) {
+	var found *vultrInstance
+
+	query := "?label=" + url.QueryEscape(name)
+	err := cloud.list(func(cursor string) (string, error) {
+		var response struct {
+			Instances []vultrInstance `json:"instances"`
+			Meta      vultrMeta       `json:"meta"`
+		}
+		err := cloud.rest.do("GET", "/instances"+query+strings.Replace(cursor, "?", "&", 1), nil, &response)
+
+		for i, instance := range response.Instances {
+			if strings.EqualFold(instance.Label, name) {
+				found = &response.Instances[i]
+				return "", err
+			}
+		}
+
+		return response.Meta.Links.Next, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list instances: %v", err)
+	}
+
+	if found == nil {
+		return nil, newNotExistsError("server", name, nil)
+	}
+
+	return found, nil
+}
+
+// The UUID of the server, the instance is looked up if the id isn't known yet
+func (cloud *VultrCloud) instanceId(server *Server) (string, error) {
+	if id, ok := cloud.ids.get(server.Id); ok {
+		return id, nil
+	}
+
+	instance, err := cloud.findInstance(server.Name)
+	if err != nil {
+		return "", err
+	}
+
+	return instance.Id, nil
+}
+
+// Calls the function for every page with the cursor query starting with '?' or an empty
+// string for the first page. The function returns the cursor of the next page.
+func (cloud *VultrCloud) list(page func(cursor string) (string, error)) error {
+	cursor := ""
+
+	for {
+		next, err := page(cursor)
+		if err != nil {
+			return err
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = "?cursor=" + url.QueryEscape(next)
+	}
+}
+
+func (cloud *VultrCloud) toVultrSnapshot(snapshot vultrSnapshot) *Snapshot {
+	// Snapshots without a valid creation date are treated as the oldest ones
+	created, _ := time.Parse(time.RFC3339, snapshot.DateCreated)
+
+	return &Snapshot{
+		Name:    snapshot.Description,
+		Id:      cloud.ids.add(snapshot.Id),
+		Created: created,
+	}
+}
+
+func (cloud *VultrCloud) toVultrServer(instance *vultrInstance) *Server {
+	status := StatusOff
+
+	switch {
+	case instance.Status == "pending":
+		status = StatusStartup
+	case instance.Status == "active" && instance.PowerStatus == "running":
+		// The server status is 'installingbooting' until the instance is reachable
+		if instance.ServerStatus == "ok" {
+			status = StatusActive
+		} else {
+			status = StatusStartup
+		}
+	}
+
+	ip := instance.MainIp
+	// The ip is assigned after the creation
+	if ip == "0.0.0.0" {
+		ip = ""
+	}
+
+	return &Server{
+		Name:     instance.Label,
+		Id:       cloud.ids.add(instance.Id),
+		Ip:       ip,
+		Status:   status,
+		Provider: vultrProvider,
+	}
+}
+
+func newVultrCloud(cfg *config.Config) *VultrCloud {
+	endpoint := cfg.Cloud.Endpoint
+	if endpoint == "" {
+		endpoint = vultrEndpoint
+	}
+
+	return &VultrCloud{
+		rest: newRestClient(endpoint, cfg.Cloud.Token),
+		ids:  newIdMap(),
+	}
+}
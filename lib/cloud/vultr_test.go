@@ -0,0 +1,147 @@
+package cloud
+
+import (
+	"encoding/json"
+	"start-my-game/lib/config"
+	"testing"
+)
+
+func newTestVultrCloud(t *testing.T, responses map[string][]string) (*VultrCloud, *recordedApi) {
+	api, url := newRecordedApi(t, responses)
+
+	cfg := &config.Config{}
+	cfg.Cloud.Endpoint = url
+	cfg.Cloud.Token = "token"
+
+	return newVultrCloud(cfg), api
+}
+
+func TestVultrListCursor(t *testing.T) {
+	first, _ := json.Marshal(map[string]interface{}{
+		"ssh_keys": []vultrSshKey{{Id: "a1b2c3d4-0000-0000-0000-000000000001", Name: "laptop", SshKey: "ssh-ed25519 invalid"}},
+		"meta":     map[string]interface{}{"total": 2, "links": map[string]string{"next": "bmV4dF9fMQ==", "prev": ""}},
+	})
+	second, _ := json.Marshal(map[string]interface{}{
+		"ssh_keys": []vultrSshKey{{Id: "a1b2c3d4-0000-0000-0000-000000000002", Name: "server", SshKey: testPublicKey}},
+		"meta":     map[string]interface{}{"total": 2, "links": map[string]string{"next": "", "prev": "cHJldl9fMQ=="}},
+	})
+
+	cloud, api := newTestVultrCloud(t, map[string][]string{
+		"GET /ssh-keys":                         {string(first)},
+		"GET /ssh-keys?cursor=bmV4dF9fMQ%3D%3D": {string(second)},
+	})
+
+	id, err := cloud.GetSSHKey(testKeyFingerprint)
+	if err != nil {
+		t.Fatalf("GetSSHKey failed: %v", err)
+	}
+
+	if uuid, _ := cloud.ids.get(id); uuid != "a1b2c3d4-0000-0000-0000-000000000002" {
+		t.Errorf("got key %v, expected the one of the second page", uuid)
+	}
+
+	if requests := api.received(); len(requests) != 2 {
+		t.Errorf("expected 2 requests, got %v", requests)
+	}
+}
+
+func TestVultrFindInstanceCursor(t *testing.T) {
+	cloud, api := newTestVultrCloud(t, map[string][]string{
+		"GET /instances?label=gmod": {`{
+			"instances": [{"id": "cb676a46-0000-0000-0000-000000000001", "label": "gmod-test", "main_ip": "192.0.2.1",
+				"status": "active", "power_status": "running", "server_status": "ok"}],
+			"meta": {"total": 2, "links": {"next": "bmV4dF9fMg==", "prev": ""}}
+		}`},
+		// The cursor must be appended to the label filter instead of starting a second query
+		"GET /instances?label=gmod&cursor=bmV4dF9fMg%3D%3D": {`{
+			"instances": [{"id": "cb676a46-0000-0000-0000-000000000002", "label": "gmod", "main_ip": "192.0.2.2",
+				"status": "active", "power_status": "running", "server_status": "ok"}],
+			"meta": {"total": 2, "links": {"next": "", "prev": "cHJldl9fMg=="}}
+		}`},
+		"GET /instances?label=missing": {`{"instances": [], "meta": {"total": 0, "links": {"next": "", "prev": ""}}}`},
+	})
+
+	server, err := cloud.GetServer("gmod")
+	if err != nil {
+		t.Fatalf("GetServer failed: %v", err)
+	}
+
+	if uuid, _ := cloud.ids.get(server.Id); uuid != "cb676a46-0000-0000-0000-000000000002" || server.Ip != "192.0.2.2" {
+		t.Errorf("got server %+v (%v), expected the one of the second page", server, uuid)
+	}
+
+	_, err = cloud.GetServer("missing")
+	if !IsNotExistsError(err) {
+		t.Errorf("expected a not exists error, got %v", err)
+	}
+
+	if requests := api.received(); len(requests) != 3 {
+		t.Errorf("expected 3 requests, got %v", requests)
+	}
+}
+
+func TestVultrUnassignedIp(t *testing.T) {
+	cloud, _ := newTestVultrCloud(t, map[string][]string{
+		"GET /instances?label=gmod": {
+			`{
+				"instances": [{"id": "cb676a46-0000-0000-0000-000000000001", "label": "gmod", "main_ip": "0.0.0.0",
+					"status": "pending", "power_status": "running", "server_status": "none"}],
+				"meta": {"total": 1, "links": {"next": "", "prev": ""}}
+			}`,
+			`{
+				"instances": [{"id": "cb676a46-0000-0000-0000-000000000001", "label": "gmod", "main_ip": "192.0.2.1",
+					"status": "active", "power_status": "running", "server_status": "installingbooting"}],
+				"meta": {"total": 1, "links": {"next": "", "prev": ""}}
+			}`,
+		},
+	})
+
+	server, err := cloud.GetServer("gmod")
+	if err != nil {
+		t.Fatalf("GetServer failed: %v", err)
+	}
+	if server.Ip != "" || server.Status != StatusStartup {
+		t.Errorf("got server %+v, expected no ip while it's created", server)
+	}
+
+	server, err = cloud.GetServer("gmod")
+	if err != nil {
+		t.Fatalf("GetServer failed: %v", err)
+	}
+	if server.Ip != "192.0.2.1" {
+		t.Errorf("got ip %v after the creation, expected 192.0.2.1", server.Ip)
+	}
+}
+
+func TestToVultrServer(t *testing.T) {
+	cloud := newVultrCloud(&config.Config{})
+
+	for _, test := range []struct {
+		status       string
+		powerStatus  string
+		serverStatus string
+		expected     string
+	}{
+		{"pending", "running", "none", StatusStartup},
+		{"active", "running", "installingbooting", StatusStartup},
+		{"active", "running", "locked", StatusStartup},
+		{"active", "running", "ok", StatusActive},
+		{"active", "stopped", "ok", StatusOff},
+		{"suspended", "stopped", "ok", StatusOff},
+		{"resizing", "running", "ok", StatusOff},
+	} {
+		server := cloud.toVultrServer(&vultrInstance{
+			Id:           "cb676a46-0000-0000-0000-000000000001",
+			Label:        "gmod",
+			MainIp:       "192.0.2.1",
+			Status:       test.status,
+			PowerStatus:  test.powerStatus,
+			ServerStatus: test.serverStatus,
+		})
+
+		if server.Status != test.expected {
+			t.Errorf("%v/%v/%v is mapped to %v, expected %v", test.status, test.powerStatus, test.serverStatus,
+				server.Status, test.expected)
+		}
+	}
+}
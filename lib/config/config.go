@@ -9,22 +9,98 @@ import (
 
 const configPath string = "config.json"
 
+// The token of the default config, which must be replaced before the start
+const PlaceholderToken = "YourSecretToken"
+
 type Config struct {
 	Web   Web   `json:"web"`
-	Gmod  Gmod  `json:"gmod"`
+	Game  Game  `json:"game"`
 	Cloud Cloud `json:"cloud"`
+	// Messages about the server are sent to every notifier
+	Notifications []Notification `json:"notifications"`
+	Schedule      *Schedule      `json:"schedule,omitempty"`
+	// Replaced by Game, only read to migrate old config files
+	Gmod *Game `json:"gmod,omitempty"`
 }
 
 type Web struct {
 	Port       int    `json:"port"`
 	CorsDomain string `json:"cors_domain"`
+	// Requests must contain one of the tokens as bearer authorization, at least one is required
+	Tokens []Token `json:"tokens"`
+	// Allows requesting the status and its event stream without a token, the metrics always
+	// require a token, because they contain the costs and the errors of the operations
+	PublicStatus bool `json:"public_status"`
+	// The web interface served at /, it's disabled if not set
+	Ui *Ui `json:"ui,omitempty"`
+}
+
+type Ui struct {
+	Enabled bool `json:"enabled"`
+	// Shown as heading and page title
+	Title string `json:"title"`
+	// Either "en" or "de"
+	Language string `json:"language"`
+}
+
+type Token struct {
+	// Shown in the log for requests with the token
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// Either "viewer", "starter" or "admin"
+	Role string `json:"role"`
+}
+
+type Notification struct {
+	// Either "discord" or "webhook"
+	Type string `json:"type"`
+	// The webhook URL
+	Url string `json:"url"`
+	// Only these events are sent, the default depends on the type.
+	// Possible events are "start", "online", "startup_error", "idle_warning", "shutdown_error",
+	// "destroyed" and "transition" for all other changes of the server state.
+	Events []string `json:"events"`
+	// Key for the HMAC-SHA256 signature of generic webhooks
+	Secret string `json:"secret"`
 }
 
-type Gmod struct {
+// Cron expressions with the fields minute, hour, day of month, month and day of week, e.g. "45 19 * * fri"
+type Schedule struct {
+	// IANA name like "Europe/Berlin", the local time zone is used if it's empty
+	TimeZone string `json:"time_zone"`
+	// The server is started at these times
+	Prewarm    []string     `json:"prewarm"`
+	QuietHours []QuietHours `json:"quiet_hours"`
+}
+
+type QuietHours struct {
+	// Must match every minute of the quiet hours, e.g. "* 0-7 * * *"
+	When string `json:"when"`
+	// Refuses all start requests, scheduled starts aren't affected
+	RefuseStart bool `json:"refuse_start"`
+	// Replaces shutdown_after of the game if it's set
+	ShutdownAfter int `json:"shutdown_after"`
+}
+
+type Game struct {
+	// Selects the game adapter, either "gmod" or "minecraft"
+	Type          string `json:"type"`
 	Port          int    `json:"port"`
 	Password      string `json:"rcon_password"`
 	CheckInterval int    `json:"check_interval"`
 	ShutdownAfter int    `json:"shutdown_after"`
+	// Either "rcon" or "query", the latter doesn't need the rcon password
+	CheckMethod string `json:"check_method"`
+	// Port for server queries, if it differs from the game port
+	QueryPort int `json:"query_port"`
+	// Port for rcon, if it differs from the game port (always the case for Minecraft)
+	RconPort int `json:"rcon_port"`
+	// Minutes before the shutdown in which the players are warned, defaults to the check interval
+	ShutdownWarning int `json:"shutdown_warning"`
+	// Seconds between the player checks while warning, defaults to 30
+	WarningCheckInterval int `json:"warning_check_interval"`
+	// Sent to the game chat, {minutes} is replaced by the minutes until the shutdown
+	WarningMessage string `json:"warning_message"`
 }
 
 type Cloud struct {
@@ -33,8 +109,84 @@ type Cloud struct {
 	ServerName string `json:"server_name"`
 	ServerType string `json:"server_type"`
 	Region     string `json:"region"`
-	Snapshot   string `json:"snapshot"`
-	SshKey     string `json:"ssh_key"`
+	// Matches snapshots named exactly like this or starting with it followed by a space
+	Snapshot string `json:"snapshot"`
+	SshKey   string `json:"ssh_key"`
+	// Overrides the API URL of providers without an official library, e.g. for a local stand-in.
+	// The docker provider accepts unix:///path/to/docker.sock or tcp://host:port and the
+	// libvirt provider a connection URI like qemu:///system.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Takes a new snapshot before the server is destroyed, which is used for the next creation
+	SnapshotOnShutdown bool `json:"snapshot_on_shutdown"`
+	// Deletes old snapshots taken on shutdown, all of them are kept if it's not set
+	Retention *Retention `json:"retention,omitempty"`
+	// Limits the costs of the server per month, there's no limit if it's not set
+	Budget   *Budget   `json:"budget,omitempty"`
+	Fake     *Fake     `json:"fake,omitempty"`
+	Libvirt  *Libvirt  `json:"libvirt,omitempty"`
+	Physical *Physical `json:"physical,omitempty"`
+}
+
+type Budget struct {
+	// Maximum costs per calendar month in the currency of the prices
+	Monthly float64 `json:"monthly"`
+	// Prices per hour by provider and server type like {"hetzner": {"cx21": 0.01}}, the price of
+	// the provider is used for missing server types
+	Prices map[string]map[string]float64 `json:"prices"`
+	// Sent to the game chat before the shutdown, {minutes} is replaced by the minutes until the shutdown
+	WarningMessage string `json:"warning_message"`
+}
+
+// A snapshot is kept if it's matched by one of the rules, the newest snapshot is always kept
+type Retention struct {
+	// Number of the newest snapshots to keep
+	KeepLast int `json:"keep_last"`
+	// Keeps the newest snapshot of each day for the given number of days
+	KeepDailyDays int `json:"keep_daily_days"`
+}
+
+// Only used by the provider "fake"
+type Fake struct {
+	// Delay of every API call in milliseconds
+	Latency int `json:"latency"`
+	// Seconds until a created or started server is active
+	BootTime int    `json:"boot_time"`
+	Ip       string `json:"ip"`
+	// Names of the cloud methods which always fail, e.g. "CreateServer"
+	Failures    []string `json:"failures"`
+	HourlyPrice float64  `json:"hourly_price"`
+}
+
+// Only used by the provider "libvirt"
+type Libvirt struct {
+	// Contains the base images named like the snapshot with the extension .qcow2. The disks of
+	// the servers are created in its subdirectory 'servers'.
+	ImageDir string `json:"image_dir"`
+	// The DHCP leases of the network are used to find the IP of the server
+	Network string `json:"network"`
+	// Memory of the server in MiB
+	Memory int `json:"memory"`
+	Cpus   int `json:"cpus"`
+}
+
+// Only used by the provider "physical"
+type Physical struct {
+	// The MAC address of the network card, which receives the Wake-on-LAN packet
+	Mac string `json:"mac"`
+	// Where the packet is sent to, 255.255.255.255:9 is used if it's not set
+	Broadcast string `json:"broadcast"`
+	// Address of the machine, which is used to reach the game
+	Ip string `json:"ip"`
+	// The machine is online if it accepts connections on the port, the SSH port is used if it's not set
+	CheckPort int `json:"check_port"`
+	// The SSH connection is used to shut down the machine, the port defaults to 22
+	SshPort    int    `json:"ssh_port"`
+	SshUser    string `json:"ssh_user"`
+	SshKeyFile string `json:"ssh_key_file"`
+	// Defaults to "sudo poweroff"
+	ShutdownCommand string `json:"shutdown_command"`
+	// Maximum seconds from the packet until the machine is online, defaults to 300
+	BootTime int `json:"boot_time"`
 }
 
 func Read() (*Config, error) {
@@ -50,6 +202,22 @@ func Read() (*Config, error) {
 		return nil, fmt.Errorf("can't read config: %v", err)
 	}
 
+	// Config files created before the game section was introduced
+	if conf.Gmod != nil && conf.Game.Type == "" {
+		conf.Game = *conf.Gmod
+		conf.Gmod = nil
+	}
+
+	if conf.Game.Type == "" {
+		conf.Game.Type = "gmod"
+	}
+
+	for _, token := range conf.Web.Tokens {
+		if token.Token == PlaceholderToken {
+			return nil, fmt.Errorf("can't use config: replace the placeholder token of '%v'", token.Name)
+		}
+	}
+
 	return &conf, nil
 }
 
@@ -68,13 +236,31 @@ func CreateIfNotExists() (bool, error) {
 			Snapshot:   "YourSnapshotName",
 			SshKey:     "YourSshKeyFingerprint",
 		},
-		Gmod: Gmod{
-			Password:      "YourRconPassword",
-			Port:          27015,
-			CheckInterval: 5,
-			ShutdownAfter: 60,
+		Game: Game{
+			Type:                 "gmod",
+			Password:             "YourRconPassword",
+			Port:                 27015,
+			CheckMethod:          "rcon",
+			CheckInterval:        5,
+			ShutdownAfter:        60,
+			ShutdownWarning:      5,
+			WarningCheckInterval: 30,
+			WarningMessage:       "Nobody is playing, the server shuts down in {minutes} minutes",
+		},
+		Web: Web{
+			Port:       8011,
+			CorsDomain: "http://ttt.example.com",
+			Tokens: []Token{
+				{Name: "YourName", Token: PlaceholderToken, Role: "admin"},
+			},
+			PublicStatus: true,
+			Ui: &Ui{
+				Enabled:  true,
+				Title:    "YourServerName",
+				Language: "en",
+			},
 		},
-		Web: Web{Port: 8011, CorsDomain: "http://ttt.example.com"},
+		Notifications: []Notification{},
 	}
 
 	bytes, err := json.MarshalIndent(defaultConf, "", "    ")
@@ -0,0 +1,57 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"start-my-game/lib/config"
+	"strings"
+)
+
+// Connects to the game server running on the cloud server with the given ip
+type Adapter interface {
+	GetType() string
+	// Returns no error as soon as the game server is ready for players
+	Probe(ctx context.Context, ip string) error
+	ServerInfo(ctx context.Context, ip string) (*ServerInfo, error)
+	Command(ctx context.Context, ip string, command string) (string, error)
+	// Sends a chat message to all players
+	Broadcast(ctx context.Context, ip string, message string) error
+	// Prepares the game server for the shutdown of the cloud server, e.g. by saving the world
+	Shutdown(ctx context.Context, ip string) error
+	// The address players use to join the game server, a link if the game supports it
+	ConnectLink(ip string) string
+	// Closes all open connections
+	Close() error
+}
+
+type ServerInfo struct {
+	Name   string
+	Online int
+	Max    int
+	// Optional fields, which aren't provided by every game or check method
+	Map      string
+	GameMode string
+	Version  string
+	Bots     int
+	Vac      bool
+	Players  []string
+}
+
+func NewAdapter(config *config.Config) (Adapter, error) {
+	gameType := strings.ToLower(config.Game.Type)
+
+	var adapter Adapter
+
+	switch gameType {
+	case gmodType:
+		adapter = newGmodAdapter(config)
+	case minecraftType:
+		adapter = newMinecraftAdapter(config)
+	}
+
+	if adapter != nil {
+		return adapter, nil
+	}
+
+	return nil, fmt.Errorf("game with type '%v' not found", gameType)
+}
@@ -0,0 +1,99 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"start-my-game/lib/config"
+	"start-my-game/lib/gmod"
+)
+
+const gmodType string = "gmod"
+
+type GmodAdapter struct {
+	config *config.Config
+	rcon   rconCache
+}
+
+func (adapter *GmodAdapter) GetType() string {
+	return gmodType
+}
+
+func (adapter *GmodAdapter) Probe(ctx context.Context, ip string) error {
+	if adapter.config.Game.CheckMethod == gmod.CheckMethodQuery {
+		return gmod.NewQuery(ip, adapter.config).Ping(ctx)
+	}
+
+	return adapter.getRcon(ip).Ping(ctx)
+}
+
+func (adapter *GmodAdapter) ServerInfo(ctx context.Context, ip string) (*ServerInfo, error) {
+	var info *gmod.GServerInfo
+	var err error
+
+	if adapter.config.Game.CheckMethod == gmod.CheckMethodQuery {
+		info, err = gmod.NewQuery(ip, adapter.config).ServerStatus(ctx)
+	} else {
+		info, err = adapter.getRcon(ip).ServerStatus(ctx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	players := make([]string, 0, len(info.Players))
+	for _, player := range info.Players {
+		players = append(players, player.Name)
+	}
+
+	return &ServerInfo{
+		Name:     info.Name,
+		Online:   info.Online,
+		Max:      info.Max,
+		Map:      info.Map,
+		GameMode: info.GameMode,
+		Bots:     info.Bots,
+		Vac:      info.Vac,
+		Players:  players,
+	}, nil
+}
+
+func (adapter *GmodAdapter) Command(ctx context.Context, ip string, command string) (string, error) {
+	return adapter.getRcon(ip).Execute(ctx, command)
+}
+
+func (adapter *GmodAdapter) Broadcast(ctx context.Context, ip string, message string) error {
+	_, err := adapter.Command(ctx, ip, "say "+message)
+	return err
+}
+
+// Garry's Mod stores everything important on its own
+func (adapter *GmodAdapter) Shutdown(ctx context.Context, ip string) error {
+	return nil
+}
+
+func (adapter *GmodAdapter) ConnectLink(ip string) string {
+	port := adapter.config.Game.Port
+	if port == 0 {
+		return fmt.Sprintf("steam://connect/%v", ip)
+	}
+
+	return fmt.Sprintf("steam://connect/%v:%v", ip, port)
+}
+
+func (adapter *GmodAdapter) Close() error {
+	return adapter.rcon.close()
+}
+
+// Reuses the rcon connection as long as the server keeps its ip
+func (adapter *GmodAdapter) getRcon(ip string) *gmod.Rcon {
+	return adapter.rcon.get(ip, func() io.Closer {
+		return gmod.NewRcon(ip, adapter.config)
+	}).(*gmod.Rcon)
+}
+
+func newGmodAdapter(config *config.Config) *GmodAdapter {
+	return &GmodAdapter{
+		config: config,
+	}
+}
@@ -0,0 +1,101 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"start-my-game/lib/config"
+	"start-my-game/lib/minecraft"
+	"start-my-game/lib/rcon"
+	"strings"
+)
+
+// Java Edition servers
+const minecraftType string = "minecraft"
+
+const (
+	minecraftDefaultPort     = 25565
+	minecraftDefaultRconPort = 25575
+)
+
+type MinecraftAdapter struct {
+	config *config.Config
+	rcon   rconCache
+}
+
+func (adapter *MinecraftAdapter) GetType() string {
+	return minecraftType
+}
+
+func (adapter *MinecraftAdapter) Probe(ctx context.Context, ip string) error {
+	_, err := minecraft.Ping(ctx, adapter.address(ip))
+	return err
+}
+
+func (adapter *MinecraftAdapter) ServerInfo(ctx context.Context, ip string) (*ServerInfo, error) {
+	status, err := minecraft.Ping(ctx, adapter.address(ip))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServerInfo{
+		Name:    strings.TrimSpace(status.Motd),
+		Online:  status.Online,
+		Max:     status.Max,
+		Version: status.Version,
+		Players: status.Players,
+	}, nil
+}
+
+func (adapter *MinecraftAdapter) Command(ctx context.Context, ip string, command string) (string, error) {
+	return adapter.getRcon(ip).Execute(ctx, command)
+}
+
+func (adapter *MinecraftAdapter) Broadcast(ctx context.Context, ip string, message string) error {
+	_, err := adapter.Command(ctx, ip, "say "+message)
+	return err
+}
+
+// Writes the world to the disk, so no progress is lost by stopping the server
+func (adapter *MinecraftAdapter) Shutdown(ctx context.Context, ip string) error {
+	_, err := adapter.Command(ctx, ip, "save-all")
+	return err
+}
+
+// The Java Edition has no links to join a server
+func (adapter *MinecraftAdapter) ConnectLink(ip string) string {
+	return adapter.address(ip)
+}
+
+func (adapter *MinecraftAdapter) Close() error {
+	return adapter.rcon.close()
+}
+
+func (adapter *MinecraftAdapter) address(ip string) string {
+	port := adapter.config.Game.Port
+	if port == 0 {
+		port = minecraftDefaultPort
+	}
+
+	return fmt.Sprintf("%v:%v", ip, port)
+}
+
+// Reuses the rcon connection as long as the server keeps its ip
+func (adapter *MinecraftAdapter) getRcon(ip string) *rcon.Client {
+	return adapter.rcon.get(ip, func() io.Closer {
+		port := adapter.config.Game.RconPort
+		if port == 0 {
+			port = minecraftDefaultRconPort
+		}
+
+		// Minecraft doesn't mirror empty response value packets, so multi packet responses can't be detected
+		address := fmt.Sprintf("%v:%v", ip, port)
+		return rcon.NewClient(address, adapter.config.Game.Password, false)
+	}).(*rcon.Client)
+}
+
+func newMinecraftAdapter(config *config.Config) *MinecraftAdapter {
+	return &MinecraftAdapter{
+		config: config,
+	}
+}
@@ -0,0 +1,49 @@
+package game
+
+import (
+	"io"
+	"sync"
+)
+
+// Keeps the rcon connection of the game server as long as the server keeps its ip
+type rconCache struct {
+	mutex sync.Mutex
+	rcon  io.Closer
+	ip    string
+}
+
+// Returns the connection to the ip, it's created by connect if there's none for the ip yet
+func (cache *rconCache) get(ip string, connect func() io.Closer) io.Closer {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if cache.rcon != nil && cache.ip == ip {
+		return cache.rcon
+	}
+
+	_ = cache.closeRcon()
+	cache.rcon = connect()
+	cache.ip = ip
+
+	return cache.rcon
+}
+
+func (cache *rconCache) close() error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return cache.closeRcon()
+}
+
+// The mutex must be locked while calling this method
+func (cache *rconCache) closeRcon() error {
+	if cache.rcon == nil {
+		return nil
+	}
+
+	err := cache.rcon.Close()
+	cache.rcon = nil
+	cache.ip = ""
+
+	return err
+}
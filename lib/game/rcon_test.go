@@ -0,0 +1,39 @@
+package game
+
+import (
+	"io"
+	"testing"
+)
+
+type testConnection struct {
+	closed bool
+}
+
+func (connection *testConnection) Close() error {
+	connection.closed = true
+	return nil
+}
+
+func TestRconCache(t *testing.T) {
+	cache := rconCache{}
+	connect := func() io.Closer { return &testConnection{} }
+
+	first := cache.get("192.0.2.1", connect).(*testConnection)
+	if cache.get("192.0.2.1", connect) != first {
+		t.Errorf("the connection to the same ip wasn't reused")
+	}
+
+	// A new ip belongs to a new server
+	second := cache.get("192.0.2.2", connect).(*testConnection)
+	if second == first || !first.closed {
+		t.Errorf("the connection to the previous ip wasn't replaced")
+	}
+
+	_ = cache.close()
+	if !second.closed {
+		t.Errorf("the connection wasn't closed")
+	}
+	if cache.get("192.0.2.2", connect) == second {
+		t.Errorf("a closed connection was reused")
+	}
+}
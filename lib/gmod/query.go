@@ -0,0 +1,351 @@
+package gmod
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"start-my-game/lib/config"
+	"strings"
+	"time"
+)
+
+// https://developer.valvesoftware.com/wiki/Server_queries
+const (
+	headerSingle int32 = -1
+	headerSplit  int32 = -2
+
+	requestInfo       byte = 'T'
+	requestPlayer     byte = 'U'
+	responseInfo      byte = 'I'
+	responsePlayer    byte = 'D'
+	responseChallenge byte = 'A'
+
+	// Used if the context has no deadline
+	queryTimeout = 5 * time.Second
+	// Maximum size of a single UDP packet sent by Source servers
+	maxQueryPacketSize = 1400
+)
+
+const (
+	CheckMethodRcon  = "rcon"
+	CheckMethodQuery = "query"
+)
+
+// Source Engine Query client, which works without the rcon password
+type Query struct {
+	address string
+}
+
+func (query *Query) Ping(ctx context.Context) error {
+	_, err := query.ServerInfo(ctx)
+	return err
+}
+
+// Combines the responses of A2S_INFO and A2S_PLAYER
+func (query *Query) ServerStatus(ctx context.Context) (*GServerInfo, error) {
+	info, err := query.ServerInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	players, err := query.Players(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Players = players
+
+	return info, nil
+}
+
+func (query *Query) ServerInfo(ctx context.Context) (*GServerInfo, error) {
+	request := append([]byte{requestInfo}, "Source Engine Query\x00"...)
+
+	response, err := query.request(ctx, request, false)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't query server info of %v: %v", query.address, err)
+	}
+
+	info, err := parseInfo(response)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse server info of %v: %v", query.address, err)
+	}
+
+	return info, nil
+}
+
+func (query *Query) Players(ctx context.Context) ([]Player, error) {
+	request := []byte{requestPlayer}
+
+	response, err := query.request(ctx, request, true)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't query players of %v: %v", query.address, err)
+	}
+
+	players, err := parsePlayers(response)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse players of %v: %v", query.address, err)
+	}
+
+	return players, nil
+}
+
+// Sends the request and answers a possible challenge.
+// If placeholder is true, the request initially contains the challenge -1 as required by A2S_PLAYER.
+func (query *Query) request(ctx context.Context, request []byte, placeholder bool) (*queryReader, error) {
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "udp", query.address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(queryTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	challenge := []byte(nil)
+	if placeholder {
+		challenge = []byte{0xFF, 0xFF, 0xFF, 0xFF}
+	}
+
+	// A server answers at most once with a challenge
+	for i := 0; i < 2; i++ {
+		err = writeQuery(conn, request, challenge)
+		if err != nil {
+			return nil, err
+		}
+
+		payload, err := readQuery(conn)
+		if err != nil {
+			return nil, err
+		}
+
+		reader := &queryReader{reader: bytes.NewReader(payload)}
+		kind := reader.byte()
+
+		if kind != responseChallenge {
+			reader.kind = kind
+			return reader, reader.err
+		}
+
+		challenge = reader.bytes(4)
+		if reader.err != nil {
+			return nil, fmt.Errorf("invalid challenge: %v", reader.err)
+		}
+	}
+
+	return nil, fmt.Errorf("server answered with a challenge twice")
+}
+
+func writeQuery(conn net.Conn, request []byte, challenge []byte) error {
+	var buffer bytes.Buffer
+
+	_ = binary.Write(&buffer, binary.LittleEndian, headerSingle)
+	buffer.Write(request)
+	buffer.Write(challenge)
+
+	_, err := conn.Write(buffer.Bytes())
+	return err
+}
+
+// Reads a response and reassembles it, if it's split into multiple packets
+func readQuery(conn net.Conn) ([]byte, error) {
+	var parts [][]byte
+	received := 0
+
+	for {
+		data := make([]byte, maxQueryPacketSize)
+		n, err := conn.Read(data)
+		if err != nil {
+			return nil, err
+		}
+
+		reader := &queryReader{reader: bytes.NewReader(data[:n])}
+		header := reader.int32()
+
+		if header == headerSingle {
+			return reader.rest(), reader.err
+		}
+
+		if header != headerSplit {
+			return nil, fmt.Errorf("invalid packet header %v", header)
+		}
+
+		id := reader.int32()
+		total := int(reader.byte())
+		number := int(reader.byte())
+		_ = reader.int16()
+
+		if reader.err != nil {
+			return nil, fmt.Errorf("invalid split packet: %v", reader.err)
+		}
+
+		if uint32(id)&0x80000000 != 0 {
+			return nil, fmt.Errorf("compressed responses aren't supported")
+		}
+
+		if total == 0 || number >= total {
+			return nil, fmt.Errorf("invalid split packet %v of %v", number, total)
+		}
+
+		if parts == nil {
+			parts = make([][]byte, total)
+		}
+
+		if number < len(parts) && parts[number] == nil {
+			parts[number] = reader.rest()
+			received++
+		}
+
+		if received == len(parts) {
+			break
+		}
+	}
+
+	payload := bytes.Join(parts, nil)
+	if len(payload) < 4 || int32(binary.LittleEndian.Uint32(payload)) != headerSingle {
+		return nil, fmt.Errorf("invalid header of the split response")
+	}
+
+	return payload[4:], nil
+}
+
+func parseInfo(reader *queryReader) (*GServerInfo, error) {
+	if reader.kind != responseInfo {
+		return nil, fmt.Errorf("unexpected response type 0x%x", reader.kind)
+	}
+
+	_ = reader.byte() // Protocol version
+	name := reader.string()
+	gameMap := reader.string()
+	_ = reader.string() // Folder
+	gameMode := reader.string()
+	_ = reader.int16() // Steam application id
+	players := int(reader.byte())
+	max := int(reader.byte())
+	bots := int(reader.byte())
+	_ = reader.byte() // Server type
+	_ = reader.byte() // Environment
+	_ = reader.byte() // Visibility
+	vac := reader.byte() == 1
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	return &GServerInfo{
+		Name:     name,
+		Online:   players - bots,
+		Max:      max,
+		Map:      gameMap,
+		GameMode: gameMode,
+		Bots:     bots,
+		Vac:      vac,
+	}, nil
+}
+
+func parsePlayers(reader *queryReader) ([]Player, error) {
+	if reader.kind != responsePlayer {
+		return nil, fmt.Errorf("unexpected response type 0x%x", reader.kind)
+	}
+
+	count := int(reader.byte())
+	players := make([]Player, 0, count)
+
+	for i := 0; i < count; i++ {
+		_ = reader.byte() // Index
+		name := reader.string()
+		score := reader.int32()
+		duration := reader.float32()
+
+		if reader.err != nil {
+			return nil, reader.err
+		}
+
+		players = append(players, Player{
+			Name:     name,
+			Score:    int(score),
+			Duration: time.Duration(float64(duration) * float64(time.Second)),
+		})
+	}
+
+	return players, nil
+}
+
+// Reads the little endian values of a query response, the first error is kept
+type queryReader struct {
+	reader *bytes.Reader
+	kind   byte
+	err    error
+}
+
+func (reader *queryReader) bytes(n int) []byte {
+	data := make([]byte, n)
+	if reader.err != nil || n == 0 {
+		return data
+	}
+
+	if read, _ := reader.reader.Read(data); read < n {
+		reader.err = fmt.Errorf("response too short")
+	}
+
+	return data
+}
+
+func (reader *queryReader) rest() []byte {
+	return reader.bytes(reader.reader.Len())
+}
+
+func (reader *queryReader) byte() byte {
+	return reader.bytes(1)[0]
+}
+
+func (reader *queryReader) int16() int16 {
+	return int16(binary.LittleEndian.Uint16(reader.bytes(2)))
+}
+
+func (reader *queryReader) int32() int32 {
+	return int32(binary.LittleEndian.Uint32(reader.bytes(4)))
+}
+
+func (reader *queryReader) float32() float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(reader.bytes(4)))
+}
+
+func (reader *queryReader) string() string {
+	if reader.err != nil {
+		return ""
+	}
+
+	var builder strings.Builder
+	for {
+		char, err := reader.reader.ReadByte()
+		if err != nil {
+			reader.err = fmt.Errorf("unterminated string")
+			return ""
+		}
+
+		if char == 0 {
+			return builder.String()
+		}
+
+		builder.WriteByte(char)
+	}
+}
+
+func NewQuery(ip string, config *config.Config) *Query {
+	port := config.Game.QueryPort
+	if port == 0 {
+		port = config.Game.Port
+	}
+
+	return &Query{
+		address: fmt.Sprintf("%v:%v", ip, port),
+	}
+}
@@ -0,0 +1,233 @@
+package gmod
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"start-my-game/lib/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testChallenge = []byte{0x4B, 0xA1, 0x3C, 0x07}
+
+// Builds the payload of an A2S_INFO response, the header isn't included
+func infoPayload(name string, players byte, max byte, bots byte) []byte {
+	var buffer bytes.Buffer
+
+	buffer.WriteByte(responseInfo)
+	buffer.WriteByte(17) // Protocol version
+	buffer.WriteString(name + "\x00")
+	buffer.WriteString("gm_construct\x00")
+	buffer.WriteString("garrysmod\x00")
+	buffer.WriteString("Sandbox\x00")
+	_ = binary.Write(&buffer, binary.LittleEndian, int16(4000))
+	buffer.Write([]byte{players, max, bots, 'd', 'l', 0, 1})
+
+	return buffer.Bytes()
+}
+
+// Builds the payload of an A2S_PLAYER response, the header isn't included
+func playerPayload(players ...Player) []byte {
+	var buffer bytes.Buffer
+
+	buffer.WriteByte(responsePlayer)
+	buffer.WriteByte(byte(len(players)))
+	for i, player := range players {
+		buffer.WriteByte(byte(i))
+		buffer.WriteString(player.Name + "\x00")
+		_ = binary.Write(&buffer, binary.LittleEndian, int32(player.Score))
+		_ = binary.Write(&buffer, binary.LittleEndian, math.Float32bits(float32(player.Duration.Seconds())))
+	}
+
+	return buffer.Bytes()
+}
+
+func withHeader(header int32, payload []byte) []byte {
+	var buffer bytes.Buffer
+
+	_ = binary.Write(&buffer, binary.LittleEndian, header)
+	buffer.Write(payload)
+
+	return buffer.Bytes()
+}
+
+// A stand-in for a Source server, which requires a challenge for both queries and splits the
+// player response into two packets
+type testQueryServer struct {
+	conn net.PacketConn
+
+	mutex    sync.Mutex
+	requests [][]byte
+}
+
+func (server *testQueryServer) serve(info []byte, players []byte) {
+	data := make([]byte, maxQueryPacketSize)
+
+	for {
+		n, addr, err := server.conn.ReadFrom(data)
+		if err != nil {
+			return
+		}
+
+		request := append([]byte(nil), data[:n]...)
+		server.mutex.Lock()
+		server.requests = append(server.requests, request)
+		server.mutex.Unlock()
+
+		challenge := request[len(request)-4:]
+		if !bytes.Equal(challenge, testChallenge) {
+			_, _ = server.conn.WriteTo(withHeader(headerSingle, append([]byte{responseChallenge}, testChallenge...)), addr)
+			continue
+		}
+
+		switch request[4] {
+		case requestInfo:
+			_, _ = server.conn.WriteTo(withHeader(headerSingle, info), addr)
+		case requestPlayer:
+			payload := withHeader(headerSingle, players)
+			middle := len(payload) / 2
+
+			// The second part is sent first, the parts are ordered by their number
+			for _, part := range []struct {
+				number byte
+				data   []byte
+			}{{1, payload[middle:]}, {0, payload[:middle]}} {
+				var buffer bytes.Buffer
+				_ = binary.Write(&buffer, binary.LittleEndian, headerSplit)
+				_ = binary.Write(&buffer, binary.LittleEndian, int32(7))
+				buffer.Write([]byte{2, part.number})
+				_ = binary.Write(&buffer, binary.LittleEndian, int16(maxQueryPacketSize))
+				buffer.Write(part.data)
+
+				_, _ = server.conn.WriteTo(buffer.Bytes(), addr)
+			}
+		}
+	}
+}
+
+func newTestQuery(t *testing.T, info []byte, players []byte) (*Query, *testQueryServer) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	server := &testQueryServer{conn: conn}
+	go server.serve(info, players)
+
+	cfg := &config.Config{}
+	cfg.Game.QueryPort = conn.LocalAddr().(*net.UDPAddr).Port
+
+	return NewQuery("127.0.0.1", cfg), server
+}
+
+func TestQueryServerStatus(t *testing.T) {
+	query, server := newTestQuery(t, infoPayload("My Server", 5, 16, 2), playerPayload(
+		Player{Name: "Alice", Score: 12, Duration: 90 * time.Second},
+		Player{Name: "Bob", Score: -1, Duration: 3 * time.Second},
+	))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status, err := query.ServerStatus(ctx)
+	if err != nil {
+		t.Fatalf("ServerStatus failed: %v", err)
+	}
+
+	if status.Name != "My Server" || status.Map != "gm_construct" || status.GameMode != "Sandbox" {
+		t.Errorf("got server info %+v", status)
+	}
+	if status.Online != 3 || status.Max != 16 || status.Bots != 2 || !status.Vac {
+		t.Errorf("got %v of %v players online with %v bots, expected 3 of 16 without the 2 bots",
+			status.Online, status.Max, status.Bots)
+	}
+
+	if len(status.Players) != 2 || status.Players[0].Name != "Alice" || status.Players[0].Score != 12 ||
+		status.Players[0].Duration != 90*time.Second || status.Players[1].Name != "Bob" || status.Players[1].Score != -1 {
+		t.Errorf("got players %+v", status.Players)
+	}
+
+	// Every query is sent twice, the second time with the challenge
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if len(server.requests) != 4 {
+		t.Fatalf("got %v requests, expected 4", len(server.requests))
+	}
+	placeholder := withHeader(headerSingle, []byte{requestPlayer, 0xFF, 0xFF, 0xFF, 0xFF})
+	if request := server.requests[2]; !bytes.Equal(request, placeholder) {
+		t.Errorf("the player query started with %x instead of the challenge -1", request)
+	}
+	if request := server.requests[1]; !bytes.Equal(request[len(request)-4:], testChallenge) {
+		t.Errorf("the info query was repeated without the challenge: %x", request)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	payload := infoPayload("Test", 1, 8, 0)
+
+	for length := 1; length < len(payload); length++ {
+		reader := &queryReader{reader: bytes.NewReader(payload[1:length]), kind: payload[0]}
+		if _, err := parseInfo(reader); err == nil {
+			t.Errorf("parsing the response truncated to %v bytes succeeded", length)
+		}
+	}
+
+	reader := &queryReader{reader: bytes.NewReader(payload[1:]), kind: payload[0]}
+	info, err := parseInfo(reader)
+	if err != nil {
+		t.Fatalf("parseInfo failed: %v", err)
+	}
+	if info.Name != "Test" || info.Online != 1 || info.Max != 8 {
+		t.Errorf("got server info %+v", info)
+	}
+
+	reader = &queryReader{reader: bytes.NewReader(payload[1:]), kind: responsePlayer}
+	if _, err := parseInfo(reader); err == nil {
+		t.Errorf("parsing a player response as server info succeeded")
+	}
+}
+
+func TestParsePlayers(t *testing.T) {
+	payload := playerPayload(Player{Name: "Alice", Score: 3, Duration: time.Minute})
+
+	for length := 2; length < len(payload); length++ {
+		reader := &queryReader{reader: bytes.NewReader(payload[1:length]), kind: payload[0]}
+		if _, err := parsePlayers(reader); err == nil {
+			t.Errorf("parsing the response truncated to %v bytes succeeded", length)
+		}
+	}
+
+	reader := &queryReader{reader: bytes.NewReader(payload[1:]), kind: payload[0]}
+	players, err := parsePlayers(reader)
+	if err != nil {
+		t.Fatalf("parsePlayers failed: %v", err)
+	}
+	if len(players) != 1 || players[0].Name != "Alice" || players[0].Duration != time.Minute {
+		t.Errorf("got players %+v", players)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	// Nobody answers on the port
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen: %v", err)
+	}
+	defer conn.Close()
+
+	cfg := &config.Config{}
+	cfg.Game.Port = conn.LocalAddr().(*net.UDPAddr).Port
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := NewQuery("127.0.0.1", cfg).ServerInfo(ctx); err == nil {
+		t.Errorf("expected a timeout without an answer")
+	}
+}
@@ -1,35 +1,50 @@
 package gmod
 
 import (
+	"context"
 	"fmt"
-	"github.com/james4k/rcon"
 	"regexp"
 	"start-my-game/lib/config"
+	"start-my-game/lib/rcon"
 	"strconv"
+	"time"
 )
 
 type Rcon struct {
-	rcon *rcon.RemoteConsole
+	client *rcon.Client
 }
 
 type GServerInfo struct {
 	Name   string
 	Online int
 	Max    int
+	// The following fields are only available using the server query
+	Map      string
+	GameMode string
+	Bots     int
+	Vac      bool
+	Players  []Player
 }
 
-func (gmod *Rcon) ServerStatus() (*GServerInfo, error) {
+type Player struct {
+	Name     string
+	Score    int
+	Duration time.Duration
+}
 
-	requestId, err := gmod.rcon.Write("status")
-	if err != nil {
-		return nil, fmt.Errorf("couldn't send request for online players: %v", err)
-	}
+// Connects to the server if there's no open connection yet
+func (gmod *Rcon) Ping(ctx context.Context) error {
+	return gmod.client.Connect(ctx)
+}
+
+func (gmod *Rcon) Execute(ctx context.Context, command string) (string, error) {
+	return gmod.client.Execute(ctx, command)
+}
 
-	response, responseId, err := gmod.rcon.Read()
+func (gmod *Rcon) ServerStatus(ctx context.Context) (*GServerInfo, error) {
+	response, err := gmod.client.Execute(ctx, "status")
 	if err != nil {
-		return nil, fmt.Errorf("coudln't read response for online players: %v", err)
-	} else if requestId != responseId {
-		return nil, fmt.Errorf("couldn't read response for online players, becuase of invalid answer id")
+		return nil, fmt.Errorf("couldn't request online players: %v", err)
 	}
 
 	online, max, err := extractPlayerCount(response)
@@ -59,7 +74,7 @@ func extractPlayerCount(response string) (int, int, error) {
 	online, errOnline := strconv.Atoi(submatch[0][1])
 	max, errMax := strconv.Atoi(submatch[0][2])
 	if errOnline != nil || errMax != nil {
-		err = fmt.Errorf("couldn't convert response match to ints: '%v'", submatch[0][0])
+		return 0, 0, fmt.Errorf("couldn't convert response match to ints: '%v'", submatch[0][0])
 	}
 
 	return online, max, nil
@@ -80,14 +95,20 @@ func extractServerName(response string) (string, error) {
 	return submatch[0][1], nil
 }
 
-func NewRcon(ip string, config *config.Config) (*Rcon, error) {
-	remoteAddr := fmt.Sprintf("%v:%v", ip, config.Gmod.Port)
-	console, err := rcon.Dial(remoteAddr, config.Gmod.Password)
-	if err != nil {
-		return nil, fmt.Errorf("couldn't connect via rcon to '%v': %v", remoteAddr, err)
+func (gmod *Rcon) Close() error {
+	return gmod.client.Close()
+}
+
+// The connection is established with the first command and kept open afterwards
+func NewRcon(ip string, config *config.Config) *Rcon {
+	port := config.Game.RconPort
+	if port == 0 {
+		port = config.Game.Port
 	}
 
+	remoteAddr := fmt.Sprintf("%v:%v", ip, port)
+
 	return &Rcon{
-		rcon: console,
-	}, nil
+		client: rcon.NewClient(remoteAddr, config.Game.Password, true),
+	}
 }
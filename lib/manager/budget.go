@@ -0,0 +1,152 @@
+package manager
+
+import (
+	"log"
+	"start-my-game/lib/cloud"
+	"strings"
+	"time"
+)
+
+const defaultBudgetMessage = "The monthly budget is exhausted, the server shuts down in {minutes} minutes"
+
+// Adds the time since the last call to the running time of the month, if a server existed in
+// the meantime. Must be called with locked mutex.
+func (manager *Manager) accrueBudget(serverExists bool) {
+	now := time.Now()
+
+	month := now.Format("2006-01")
+	if month != manager.budgetMonth {
+		manager.budgetMonth = month
+		manager.budgetSeconds = 0
+
+		// Only the time in the new month counts
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		if !manager.budgetAccruedAt.IsZero() && manager.budgetAccruedAt.Before(monthStart) {
+			manager.budgetAccruedAt = monthStart
+		}
+	}
+
+	if !manager.budgetAccruedAt.IsZero() {
+		manager.budgetSeconds += now.Sub(manager.budgetAccruedAt).Seconds()
+	}
+
+	if serverExists {
+		manager.budgetAccruedAt = now
+	} else {
+		manager.budgetAccruedAt = time.Time{}
+	}
+}
+
+// The price per hour of the configured server type, false if it's unknown
+func (manager *Manager) serverPrice() (float64, bool) {
+	budget := manager.config.Cloud.Budget
+	serverType := manager.config.Cloud.ServerType
+
+	for provider, prices := range budget.Prices {
+		if strings.EqualFold(provider, manager.config.Cloud.Provider) {
+			if price, ok := prices[serverType]; ok {
+				return price, true
+			}
+		}
+	}
+
+	manager.mutex.RLock()
+	price, known, failed := manager.hourlyPrice, manager.priceKnown, manager.priceFailed
+	manager.mutex.RUnlock()
+	if known {
+		return price, true
+	}
+	// Every check would ask the provider again otherwise
+	if failed {
+		return 0, false
+	}
+
+	// Asking again won't help, the warning is only logged once
+	if _, ok := manager.cloud.(cloud.PriceProvider); !ok {
+		manager.priceWarning.Do(func() {
+			log.Printf("The provider %v doesn't provide prices, configure the price of %v for the budget\n",
+				manager.cloud.GetProvider(), serverType)
+		})
+		return 0, false
+	}
+
+	price, err := cloud.HourlyPrice(manager.cloud, serverType, manager.config.Cloud.Region)
+	if err != nil {
+		log.Println("Couldn't get the price of the server, the budget isn't checked until it's deleted:", err)
+		manager.mutex.Lock()
+		manager.priceFailed = true
+		manager.mutex.Unlock()
+		return 0, false
+	}
+
+	manager.mutex.Lock()
+	manager.hourlyPrice = price
+	manager.priceKnown = true
+	manager.mutex.Unlock()
+
+	return price, true
+}
+
+// The costs of the server in the current month
+func (manager *Manager) monthlyCosts() (float64, bool) {
+	if manager.config.Cloud.Budget == nil {
+		return 0, false
+	}
+
+	price, ok := manager.serverPrice()
+	if !ok {
+		return 0, false
+	}
+
+	manager.mutex.Lock()
+	manager.accrueBudget(manager.activeServer != nil)
+	seconds := manager.budgetSeconds
+	manager.mutex.Unlock()
+
+	return seconds / 3600 * price, true
+}
+
+// Always false if there's no budget or the price is unknown
+func (manager *Manager) budgetExhausted() bool {
+	costs, ok := manager.monthlyCosts()
+	if !ok {
+		return false
+	}
+
+	return costs >= manager.config.Cloud.Budget.Monthly
+}
+
+// Warns the players and deletes the server regardless of the players online
+func (manager *Manager) shutdownForBudget(server *cloud.Server) {
+	state := manager.lifecycle.State()
+	if server.Status == cloud.StatusActive && (state == StateRunning || state == StateIdleWarning) {
+		err := manager.ensureState(StateIdleWarning, "the monthly budget is exhausted")
+		if err != nil {
+			log.Println("Couldn't start the budget warning:", err)
+			return
+		}
+
+		warning := manager.warningDuration()
+		message := manager.config.Cloud.Budget.WarningMessage
+		if message == "" {
+			message = defaultBudgetMessage
+		}
+
+		manager.broadcastWarning(server, message, warning)
+		time.Sleep(warning)
+	}
+
+	if !manager.beginOperation() {
+		return
+	}
+	defer manager.endOperation()
+
+	// The server could have been stopped during the warning
+	manager.UpdateActiveServer()
+	if manager.lifecycle.InStartup() || manager.getActiveServer() == nil {
+		return
+	}
+
+	log.Println("Shutting down the server, because the monthly budget is exhausted")
+	manager.deleteServer()
+}
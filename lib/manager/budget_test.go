@@ -0,0 +1,163 @@
+package manager
+
+import (
+	"fmt"
+	"start-my-game/lib/cloud"
+	"start-my-game/lib/config"
+	"start-my-game/lib/state"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Pretends the server existed for the hours in the current month
+func spendBudget(manager *Manager, hours float64) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.budgetMonth = time.Now().Format("2006-01")
+	manager.budgetSeconds = hours * 3600
+}
+
+func TestBudgetBlocksStart(t *testing.T) {
+	manager, _ := newTestManager(t, &config.Fake{HourlyPrice: 1})
+	manager.config.Cloud.Budget = &config.Budget{Monthly: 10}
+	spendBudget(manager, 11)
+
+	if status := manager.Start("test"); status != "budget_exhausted" {
+		t.Errorf("Start returned %v, expected budget_exhausted", status)
+	}
+
+	// The configured price of the provider replaces the one of the cloud
+	manager.config.Cloud.ServerType = "small"
+	manager.config.Cloud.Budget.Prices = map[string]map[string]float64{
+		"hetzner": {"small": 2},
+		"Fake":    {"small": 0.5, "large": 2},
+	}
+
+	if status := manager.Start("test"); status != "creating" {
+		t.Errorf("Start returned %v, expected creating with the configured price", status)
+	}
+	waitForState(t, manager, StateRunning)
+}
+
+func TestBudgetForcedShutdown(t *testing.T) {
+	manager, acloud := newTestManager(t, &config.Fake{HourlyPrice: 1})
+	manager.config.Cloud.Budget = &config.Budget{Monthly: 10}
+	// The players are warned without waiting
+	manager.config.Game.CheckInterval = 0
+	adapter := manager.game.(*fakeAdapter)
+
+	manager.Start("test")
+	waitForState(t, manager, StateRunning)
+	adapter.setOnline(1)
+
+	manager.check()
+	if state := manager.lifecycle.State(); state != StateRunning {
+		t.Fatalf("got state %v with players online, expected %v", state, StateRunning)
+	}
+
+	// The players online don't prevent the shutdown
+	spendBudget(manager, 11)
+	manager.check()
+	waitForState(t, manager, StateOff)
+
+	if _, err := acloud.GetServer("smg-test"); !cloud.IsNotExistsError(err) {
+		t.Errorf("expected the server to be deleted, got %v", err)
+	}
+
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+	if len(adapter.broadcasts) != 1 || !strings.Contains(adapter.broadcasts[0], "budget") {
+		t.Errorf("got the broadcasts %v, expected the budget warning", adapter.broadcasts)
+	}
+}
+
+// Counts the price lookups, which fail until err is nil
+type priceCloud struct {
+	cloud.Cloud
+	mutex   sync.Mutex
+	err     error
+	lookups int
+}
+
+func (acloud *priceCloud) HourlyPrice(serverType string, region string) (float64, error) {
+	acloud.mutex.Lock()
+	defer acloud.mutex.Unlock()
+
+	acloud.lookups++
+	return 1, acloud.err
+}
+
+func (acloud *priceCloud) recover() int {
+	acloud.mutex.Lock()
+	defer acloud.mutex.Unlock()
+
+	acloud.err = nil
+	return acloud.lookups
+}
+
+func TestPriceFailureCached(t *testing.T) {
+	manager, acloud := newTestManager(t, &config.Fake{})
+	prices := &priceCloud{Cloud: acloud, err: fmt.Errorf("unknown server type")}
+	manager.cloud = prices
+	manager.config.Cloud.Budget = &config.Budget{Monthly: 10}
+	spendBudget(manager, 11)
+
+	// Without a price the budget isn't checked
+	if status := manager.Start("test"); status != "creating" {
+		t.Fatalf("Start returned %v, expected creating without a price", status)
+	}
+	waitForState(t, manager, StateRunning)
+
+	manager.check()
+	if lookups := prices.recover(); lookups != 1 {
+		t.Errorf("the price was read %v times for the same server, expected once", lookups)
+	}
+	if manager.budgetExhausted() {
+		t.Errorf("the price was read again for the same server")
+	}
+
+	manager.Destroy()
+	waitForState(t, manager, StateOff)
+
+	if !manager.budgetExhausted() {
+		t.Errorf("the price wasn't read again after the server was deleted")
+	}
+}
+
+func TestRestoreBookedBudget(t *testing.T) {
+	manager, acloud := newTestManager(t, &config.Fake{})
+
+	// The server was deleted while the application wasn't running
+	accruedAt := time.Now().Add(-2 * time.Hour)
+	err := manager.store.Update(func(stored *state.State) {
+		stored.BudgetMonth = time.Now().Format("2006-01")
+		stored.BudgetSeconds = 3600
+		stored.BudgetAccruedAt = accruedAt
+	})
+	if err != nil {
+		t.Fatalf("couldn't update the state: %v", err)
+	}
+
+	restored := NewManager(manager.config, acloud, &fakeAdapter{}, manager.store, manager.schedule)
+
+	// Only the time in the current month counts
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	if accruedAt.Before(monthStart) {
+		accruedAt = monthStart
+	}
+	expected := 3600 + now.Sub(accruedAt).Seconds()
+
+	restored.mutex.RLock()
+	defer restored.mutex.RUnlock()
+
+	if restored.budgetSeconds < expected-60 || restored.budgetSeconds > expected {
+		t.Errorf("got %v seconds in the budget, expected about %v", restored.budgetSeconds, expected)
+	}
+	if !restored.budgetAccruedAt.IsZero() {
+		t.Errorf("the budget is still accrued without a server since %v", restored.budgetAccruedAt)
+	}
+}
@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	// Something about the manager changed, the current state can be read using Status
+	EventStatus EventType = "status"
+	// The lifecycle switched to another state
+	EventTransition EventType = "transition"
+	// Someone requested a start, which creates or starts the server
+	EventStartRequested EventType = "start_requested"
+)
+
+const (
+	// Number of events kept for subscribers which reconnect
+	eventHistorySize = 100
+	// Events are dropped for subscribers, which don't keep up
+	subscriberBuffer = 32
+)
+
+type Event struct {
+	// Increasing number, which identifies the event
+	Id   int
+	Type EventType
+	Time time.Time
+	// Only set for EventTransition
+	Transition *Transition
+	// Only set for EventStartRequested
+	Requester string
+	// The IP of the server and the players at the last check when the event happened, the
+	// status may already be different when a subscriber receives the event
+	Ip      string
+	Players int
+}
+
+type broker struct {
+	mutex       sync.Mutex
+	lastId      int
+	history     []Event
+	subscribers map[chan Event]struct{}
+}
+
+// Returns a channel receiving all following events and the events after lastId, which are still known.
+// The returned function must be called to stop the subscription.
+func (manager *Manager) Subscribe(lastId int) (<-chan Event, []Event, func()) {
+	broker := manager.events
+
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	// The ids start again after a restart of the application
+	if lastId > broker.lastId {
+		lastId = 0
+	}
+
+	var missed []Event
+	for _, event := range broker.history {
+		if event.Id > lastId {
+			missed = append(missed, event)
+		}
+	}
+
+	channel := make(chan Event, subscriberBuffer)
+	broker.subscribers[channel] = struct{}{}
+
+	unsubscribe := func() {
+		broker.mutex.Lock()
+		defer broker.mutex.Unlock()
+
+		if _, ok := broker.subscribers[channel]; ok {
+			delete(broker.subscribers, channel)
+			close(channel)
+		}
+	}
+
+	return channel, missed, unsubscribe
+}
+
+// The id of the latest event
+func (manager *Manager) LastEventId() int {
+	manager.events.mutex.Lock()
+	defer manager.events.mutex.Unlock()
+
+	return manager.events.lastId
+}
+
+// The number of subscriptions, which weren't stopped yet
+func (manager *Manager) Subscribers() int {
+	manager.events.mutex.Lock()
+	defer manager.events.mutex.Unlock()
+
+	return len(manager.events.subscribers)
+}
+
+// Adds the fields of the server to the event and sends it to all subscribers
+func (manager *Manager) publish(event Event) {
+	manager.mutex.RLock()
+	if manager.activeServer != nil {
+		event.Ip = manager.activeServer.Ip
+	}
+	if manager.lastGameInfo != nil {
+		event.Players = manager.lastGameInfo.Online
+	}
+	manager.mutex.RUnlock()
+
+	manager.events.publish(event)
+}
+
+// Assigns the id and the time to the event and sends it to all subscribers
+func (broker *broker) publish(event Event) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	broker.lastId++
+	event.Id = broker.lastId
+	event.Time = time.Now()
+
+	broker.history = append(broker.history, event)
+	if len(broker.history) > eventHistorySize {
+		broker.history = broker.history[len(broker.history)-eventHistorySize:]
+	}
+
+	for subscriber := range broker.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// Persists the state and notifies the subscribers about the change
+func (manager *Manager) changed() {
+	// Nothing changed for the subscribers until the previous state is restored
+	if manager.lifecycle == nil {
+		return
+	}
+
+	manager.persist()
+	manager.publish(Event{Type: EventStatus})
+}
+
+func newBroker() *broker {
+	return &broker{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
@@ -0,0 +1,129 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"start-my-game/lib/cloud"
+	"start-my-game/lib/game"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultWarningMessage = "Nobody is playing, the server shuts down in {minutes} minutes"
+
+// The players are warned this long before the shutdown
+func (manager *Manager) warningDuration() time.Duration {
+	if manager.config.Game.ShutdownWarning > 0 {
+		return time.Duration(manager.config.Game.ShutdownWarning) * time.Minute
+	}
+
+	return manager.interval()
+}
+
+func (manager *Manager) warningInterval() time.Duration {
+	if manager.config.Game.WarningCheckInterval > 0 {
+		return time.Duration(manager.config.Game.WarningCheckInterval) * time.Second
+	}
+
+	return 30 * time.Second
+}
+
+// Queries the game server and stores the result
+func (manager *Manager) updateGameInfo(server *cloud.Server) (*game.ServerInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gameTimeout)
+	gameInfo, err := manager.game.ServerInfo(ctx, server.Ip)
+	cancel()
+	if err != nil {
+		probeFailures.Inc("check")
+		return nil, err
+	}
+
+	manager.mutex.Lock()
+	manager.lastGameInfo = gameInfo
+	manager.mutex.Unlock()
+	recordPlayers(gameInfo.Online, gameInfo.Max)
+	manager.changed()
+
+	if gameInfo.Online > 0 {
+		manager.setLastActivePlayer(time.Now())
+	}
+
+	return gameInfo, nil
+}
+
+// Warns the players in the game and checks for players more often until the server was empty for
+// the shutdown delay. Returns false if the shutdown was aborted, because players joined or the
+// state was changed by another operation.
+func (manager *Manager) warnBeforeShutdown(server *cloud.Server) bool {
+	remaining := manager.shutdownDelay() - time.Since(manager.getLastActivePlayer())
+	if remaining <= 0 {
+		return true
+	}
+
+	err := manager.ensureState(StateIdleWarning, fmt.Sprintf("no players online, shutting down in %v",
+		remaining.Round(time.Second)))
+	if err != nil {
+		log.Println("Couldn't start the idle warning:", err)
+		return false
+	}
+
+	manager.broadcastWarning(server, manager.config.Game.WarningMessage, remaining)
+
+	for {
+		remaining = manager.shutdownDelay() - time.Since(manager.getLastActivePlayer())
+		if remaining <= 0 {
+			return true
+		}
+
+		sleep := manager.warningInterval()
+		if remaining < sleep {
+			sleep = remaining
+		}
+		time.Sleep(sleep)
+
+		if manager.lifecycle.State() != StateIdleWarning {
+			log.Println("Stopped the idle warning, because the server state changed")
+			return false
+		}
+
+		gameInfo, err := manager.updateGameInfo(server)
+		if err != nil {
+			log.Println("Couldn't read online players during the idle warning:", err)
+			continue
+		}
+
+		if gameInfo.Online > 0 {
+			log.Printf("Aborted the shutdown, %v of %v players online\n", gameInfo.Online, gameInfo.Max)
+			manager.warnIdle(StateRunning, "players joined during the idle warning")
+			return false
+		}
+	}
+}
+
+// Sends the message to the game chat, {minutes} is replaced by the minutes until the shutdown
+func (manager *Manager) broadcastWarning(server *cloud.Server, message string, remaining time.Duration) {
+	if message == "" {
+		message = defaultWarningMessage
+	}
+
+	minutes := strconv.Itoa(int(math.Ceil(remaining.Minutes())))
+	message = strings.ReplaceAll(message, "{minutes}", minutes)
+
+	ctx, cancel := context.WithTimeout(context.Background(), gameTimeout)
+	err := manager.game.Broadcast(ctx, server.Ip, message)
+	cancel()
+	if err != nil {
+		log.Println("Couldn't warn the players about the shutdown:", err)
+	}
+}
+
+// Switches between StateRunning and StateIdleWarning, unless another operation changed the state
+func (manager *Manager) warnIdle(to State, reason string) {
+	err := manager.transition(to, reason)
+	if err != nil {
+		log.Println("Couldn't change the idle warning:", err)
+	}
+}
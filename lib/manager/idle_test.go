@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"start-my-game/lib/config"
+	"testing"
+	"time"
+)
+
+func TestIdleWarningAborted(t *testing.T) {
+	manager, acloud := newTestManager(t, &config.Fake{})
+	manager.config.Game.ShutdownWarning = 5
+	manager.config.Game.WarningCheckInterval = 1
+	manager.config.Game.WarningMessage = "Shutdown in {minutes} minutes"
+	adapter := manager.game.(*fakeAdapter)
+
+	manager.Start("test")
+	waitForState(t, manager, StateRunning)
+
+	// The server shuts down in 30 seconds
+	manager.setLastActivePlayer(time.Now().Add(-manager.shutdownDelay() + 30*time.Second))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		manager.check()
+	}()
+	waitForState(t, manager, StateIdleWarning)
+
+	// The players are warned after the transition
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		adapter.mutex.Lock()
+		broadcasts := append([]string(nil), adapter.broadcasts...)
+		adapter.mutex.Unlock()
+
+		if len(broadcasts) > 0 {
+			if len(broadcasts) != 1 || broadcasts[0] != "Shutdown in 1 minutes" {
+				t.Errorf("got the broadcasts %v, expected a single warning", broadcasts)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the players weren't warned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The re-check during the warning finds the player
+	adapter.setOnline(1)
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the check didn't return after a player joined")
+	}
+
+	if state := manager.lifecycle.State(); state != StateRunning {
+		t.Errorf("got state %v after a player joined, expected %v", state, StateRunning)
+	}
+	if _, err := acloud.GetServer("smg-test"); err != nil {
+		t.Errorf("the server was deleted although a player joined: %v", err)
+	}
+	if time.Since(manager.getLastActivePlayer()) > time.Minute {
+		t.Errorf("the player wasn't recorded as active")
+	}
+}
@@ -0,0 +1,153 @@
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	StateOff            State = "off"
+	StateCreating       State = "creating"
+	StateBooting        State = "booting"
+	StateWaitingForGame State = "waiting_for_game"
+	StateRunning        State = "running"
+	StateIdleWarning    State = "idle_warning"
+	StateStopping       State = "stopping"
+	StateDestroying     State = "destroying"
+	StateError          State = "error"
+)
+
+// Number of transitions kept in the log
+const transitionLogSize = 100
+
+// All allowed transitions, every state can switch to StateError
+var transitions = map[State][]State{
+	StateOff:            {StateCreating, StateBooting, StateRunning, StateStopping, StateDestroying},
+	StateCreating:       {StateBooting},
+	StateBooting:        {StateWaitingForGame, StateDestroying},
+	StateWaitingForGame: {StateRunning, StateDestroying},
+	StateRunning:        {StateIdleWarning, StateStopping, StateDestroying, StateOff},
+	StateIdleWarning:    {StateRunning, StateStopping, StateDestroying, StateOff},
+	StateStopping:       {StateDestroying},
+	StateDestroying:     {StateOff},
+	StateError:          {StateOff, StateCreating, StateBooting, StateRunning, StateStopping, StateDestroying},
+}
+
+type Transition struct {
+	From   State     `json:"from"`
+	To     State     `json:"to"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+}
+
+// The single source of truth for the state of the game server
+type Lifecycle struct {
+	mutex sync.RWMutex
+	state State
+	since time.Time
+	log   []Transition
+}
+
+func (lifecycle *Lifecycle) State() State {
+	lifecycle.mutex.RLock()
+	defer lifecycle.mutex.RUnlock()
+
+	return lifecycle.state
+}
+
+// The time of the last transition
+func (lifecycle *Lifecycle) Since() time.Time {
+	lifecycle.mutex.RLock()
+	defer lifecycle.mutex.RUnlock()
+
+	return lifecycle.since
+}
+
+// The state and the time of the last transition
+func (lifecycle *Lifecycle) Current() (State, time.Time) {
+	lifecycle.mutex.RLock()
+	defer lifecycle.mutex.RUnlock()
+
+	return lifecycle.state, lifecycle.since
+}
+
+// The last transition or nil if there wasn't any
+func (lifecycle *Lifecycle) Last() *Transition {
+	lifecycle.mutex.RLock()
+	defer lifecycle.mutex.RUnlock()
+
+	if len(lifecycle.log) == 0 {
+		return nil
+	}
+
+	last := lifecycle.log[len(lifecycle.log)-1]
+	return &last
+}
+
+// A copy of the recent transitions, the oldest one first
+func (lifecycle *Lifecycle) Transitions() []Transition {
+	lifecycle.mutex.RLock()
+	defer lifecycle.mutex.RUnlock()
+
+	return append([]Transition(nil), lifecycle.log...)
+}
+
+// Whether the server is being created or started and the game isn't ready yet
+func (lifecycle *Lifecycle) InStartup() bool {
+	switch lifecycle.State() {
+	case StateCreating, StateBooting, StateWaitingForGame:
+		return true
+	default:
+		return false
+	}
+}
+
+func (lifecycle *Lifecycle) Transition(to State, reason string) (Transition, error) {
+	lifecycle.mutex.Lock()
+	defer lifecycle.mutex.Unlock()
+
+	from := lifecycle.state
+	if !canTransition(from, to) {
+		return Transition{}, fmt.Errorf("invalid transition from %v to %v", from, to)
+	}
+
+	transition := Transition{
+		From:   from,
+		To:     to,
+		Time:   time.Now(),
+		Reason: reason,
+	}
+
+	lifecycle.state = to
+	lifecycle.since = transition.Time
+	lifecycle.log = append(lifecycle.log, transition)
+	if len(lifecycle.log) > transitionLogSize {
+		lifecycle.log = lifecycle.log[len(lifecycle.log)-transitionLogSize:]
+	}
+
+	return transition, nil
+}
+
+func canTransition(from State, to State) bool {
+	if to == StateError {
+		return true
+	}
+
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newLifecycle(initial State) *Lifecycle {
+	return &Lifecycle{
+		state: initial,
+		since: time.Now(),
+	}
+}
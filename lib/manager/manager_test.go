@@ -0,0 +1,281 @@
+package manager
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"start-my-game/lib/cloud"
+	"start-my-game/lib/config"
+	"start-my-game/lib/game"
+	"start-my-game/lib/schedule"
+	"start-my-game/lib/state"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A game server which is always ready
+type fakeAdapter struct {
+	mutex      sync.Mutex
+	online     int
+	broadcasts []string
+}
+
+func (adapter *fakeAdapter) GetType() string {
+	return "fake"
+}
+
+func (adapter *fakeAdapter) Probe(ctx context.Context, ip string) error {
+	return nil
+}
+
+func (adapter *fakeAdapter) ServerInfo(ctx context.Context, ip string) (*game.ServerInfo, error) {
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+
+	return &game.ServerInfo{Name: "fake", Online: adapter.online, Max: 16}, nil
+}
+
+func (adapter *fakeAdapter) Command(ctx context.Context, ip string, command string) (string, error) {
+	return "", nil
+}
+
+func (adapter *fakeAdapter) Broadcast(ctx context.Context, ip string, message string) error {
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+
+	adapter.broadcasts = append(adapter.broadcasts, message)
+	return nil
+}
+
+func (adapter *fakeAdapter) setOnline(online int) {
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+
+	adapter.online = online
+}
+
+func (adapter *fakeAdapter) Shutdown(ctx context.Context, ip string) error {
+	return nil
+}
+
+func (adapter *fakeAdapter) ConnectLink(ip string) string {
+	return ip
+}
+
+func (adapter *fakeAdapter) Close() error {
+	return nil
+}
+
+// Creates a manager using the fake provider. The state is stored in a temporary directory and
+// the waiting times are shortened until the end of the test.
+func newTestManager(t *testing.T, fake *config.Fake) (*Manager, cloud.Cloud) {
+	dir, err := ioutil.TempDir("", "smg-manager")
+	if err != nil {
+		t.Fatalf("couldn't create the state directory: %v", err)
+	}
+
+	workingDir, _ := os.Getwd()
+	// The state is stored in the working directory
+	_ = os.Chdir(dir)
+
+	intervals := []*time.Duration{&bootCheckInterval, &gameCheckInterval, &shutdownWait}
+	previous := make([]time.Duration, len(intervals))
+	for i, interval := range intervals {
+		previous[i] = *interval
+		*interval = time.Millisecond
+	}
+
+	t.Cleanup(func() {
+		for i, interval := range intervals {
+			*interval = previous[i]
+		}
+		_ = os.Chdir(workingDir)
+		_ = os.RemoveAll(dir)
+	})
+
+	cfg := &config.Config{}
+	cfg.Cloud.Provider = "fake"
+	cfg.Cloud.ServerName = "smg-test"
+	cfg.Cloud.Snapshot = "gmod"
+	cfg.Cloud.SshKey = "key"
+	cfg.Cloud.Fake = fake
+	cfg.Game.Type = "fake"
+	cfg.Game.CheckInterval = 5
+	cfg.Game.ShutdownAfter = 60
+
+	acloud, err := cloud.NewCloud(cfg)
+	if err != nil {
+		t.Fatalf("couldn't create the cloud: %v", err)
+	}
+
+	store, err := state.Open()
+	if err != nil {
+		t.Fatalf("couldn't open the state: %v", err)
+	}
+
+	sched, err := schedule.New(cfg)
+	if err != nil {
+		t.Fatalf("couldn't create the schedule: %v", err)
+	}
+
+	return NewManager(cfg, acloud, &fakeAdapter{}, store, sched), acloud
+}
+
+// Waits until no operation is running and the lifecycle is in the state
+func waitForState(t *testing.T, manager *Manager, expected State) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		manager.mutex.RLock()
+		operation := manager.operation
+		manager.mutex.RUnlock()
+
+		state := manager.lifecycle.State()
+		if !operation && state == expected {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("the lifecycle is in state %v (operation running: %v), expected %v", state, operation, expected)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStartAndStop(t *testing.T) {
+	manager, acloud := newTestManager(t, &config.Fake{Ip: "192.0.2.1"})
+
+	if status := manager.Stop(false); status != "not_running" {
+		t.Errorf("Stop returned %v without a server", status)
+	}
+
+	if status := manager.Start("test"); status != "creating" {
+		t.Fatalf("Start returned %v, expected creating", status)
+	}
+	waitForState(t, manager, StateRunning)
+
+	if server := manager.Status().Server; server == nil || server.Ip != "192.0.2.1" {
+		t.Errorf("got the active server %+v, expected the one of the fake cloud", server)
+	}
+
+	if status := manager.Start("test"); status != "already_running" {
+		t.Errorf("Start returned %v for a running server", status)
+	}
+
+	if status := manager.Stop(true); status != "stopping" {
+		t.Fatalf("Stop returned %v, expected stopping", status)
+	}
+	waitForState(t, manager, StateOff)
+
+	if _, err := acloud.GetServer("smg-test"); !cloud.IsNotExistsError(err) {
+		t.Errorf("expected the server to be destroyed, got %v", err)
+	}
+
+	// The snapshot taken on the shutdown is used for the next start
+	snapshots, _ := acloud.ListSnapshots("gmod")
+	if len(snapshots) != 2 {
+		t.Errorf("got %v snapshots, expected the configured one and the one taken", len(snapshots))
+	}
+}
+
+func TestConcurrentOperations(t *testing.T) {
+	manager, acloud := newTestManager(t, &config.Fake{Latency: 1})
+	// The checks delete the server as soon as it's empty
+	manager.config.Game.ShutdownAfter = 0
+
+	operations := []func(){
+		func() { manager.Start("test") },
+		func() { manager.Stop(false) },
+		func() { manager.Destroy() },
+		func() { manager.check() },
+		func() { manager.Status() },
+		func() { manager.UpdateActiveServer() },
+	}
+
+	var group sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		group.Add(1)
+		go func(seed int64) {
+			defer group.Done()
+
+			random := rand.New(rand.NewSource(seed))
+			for i := 0; i < 50; i++ {
+				operations[random.Intn(len(operations))]()
+				time.Sleep(time.Duration(random.Intn(3)) * time.Millisecond)
+			}
+		}(int64(worker))
+	}
+	group.Wait()
+
+	// The operations started last may still be running
+	deadline := time.Now().Add(10 * time.Second)
+	for manager.lifecycle.InStartup() || manager.lifecycle.State() == StateStopping ||
+		manager.lifecycle.State() == StateDestroying {
+		if time.Now().After(deadline) {
+			t.Fatalf("the lifecycle is still in state %v", manager.lifecycle.State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, transition := range manager.lifecycle.Transitions() {
+		if transition.To == StateError {
+			t.Errorf("the lifecycle ran into an error: %v", transition.Reason)
+		}
+	}
+
+	_, err := acloud.GetServer("smg-test")
+	switch state := manager.lifecycle.State(); state {
+	case StateOff:
+		if !cloud.IsNotExistsError(err) {
+			t.Errorf("the lifecycle is off, but the server exists: %v", err)
+		}
+	case StateRunning, StateIdleWarning:
+		if err != nil {
+			t.Errorf("the lifecycle is %v, but the server doesn't exist: %v", state, err)
+		}
+	default:
+		t.Errorf("unexpected state %v after the operations", state)
+	}
+}
+
+func TestServerDeletedOutside(t *testing.T) {
+	manager, acloud := newTestManager(t, &config.Fake{})
+
+	manager.Start("test")
+	waitForState(t, manager, StateRunning)
+
+	server, _ := acloud.GetServer("smg-test")
+	err := acloud.DestroyServer(server)
+	if err != nil {
+		t.Fatalf("DestroyServer failed: %v", err)
+	}
+
+	manager.check()
+	if state := manager.lifecycle.State(); state != StateOff {
+		t.Fatalf("got state %v after the server was deleted, expected %v", state, StateOff)
+	}
+
+	if status := manager.Start("test"); status != "creating" {
+		t.Errorf("Start returned %v, expected a new server to be created", status)
+	}
+	waitForState(t, manager, StateRunning)
+}
+
+func TestDestroyFailure(t *testing.T) {
+	manager, acloud := newTestManager(t, &config.Fake{Failures: []string{"DestroyServer"}})
+
+	manager.Start("test")
+	waitForState(t, manager, StateRunning)
+
+	if status := manager.Destroy(); status != "stopping" {
+		t.Fatalf("Destroy returned %v, expected stopping", status)
+	}
+	waitForState(t, manager, StateError)
+
+	if _, err := acloud.GetServer("smg-test"); err != nil {
+		t.Errorf("the server should still exist after the failed destruction: %v", err)
+	}
+}
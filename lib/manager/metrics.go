@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"start-my-game/lib/cloud"
+	"start-my-game/lib/metrics"
+	"time"
+)
+
+var (
+	serverState = metrics.NewGauge("smg_server_state",
+		"Is 1 for the current state of the server lifecycle, 0 otherwise", "state")
+	playersOnline = metrics.NewGauge("smg_players_online",
+		"Players on the game server at the last check")
+	playersMax = metrics.NewGauge("smg_players_max",
+		"Player slots of the game server at the last check")
+	startupStepDuration = metrics.NewHistogram("smg_startup_step_duration_seconds",
+		"Duration of the single steps of a server startup", metrics.DurationBuckets, "step")
+	startupDuration = metrics.NewHistogram("smg_startup_duration_seconds",
+		"Duration from the start request until the game is ready", metrics.DurationBuckets)
+	probeFailures = metrics.NewCounter("smg_game_probe_failures_total",
+		"Failed requests to the game server while waiting for it or while checking the players", "phase")
+)
+
+func recordState(current State) {
+	for state := range transitions {
+		if state == current {
+			serverState.Set(1, string(state))
+		} else {
+			serverState.Set(0, string(state))
+		}
+	}
+}
+
+func recordPlayers(online int, max int) {
+	playersOnline.Set(float64(online))
+	playersMax.Set(float64(max))
+}
+
+// Counts the time between the creation and the destruction of the server, because the cloud
+// providers bill a server even if it's powered off. Must be called with locked mutex.
+func (manager *Manager) recordBilling(server *cloud.Server) {
+	now := time.Now()
+
+	if server != nil && manager.billedSince.IsZero() {
+		manager.billedSince = now
+	} else if server == nil && !manager.billedSince.IsZero() {
+		manager.billed += now.Sub(manager.billedSince)
+		manager.billedSince = time.Time{}
+	}
+
+	manager.accrueBudget(server != nil)
+}
+
+// Seconds the server existed since the application was started
+func (manager *Manager) billedSeconds() float64 {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	billed := manager.billed
+	if !manager.billedSince.IsZero() {
+		billed += time.Since(manager.billedSince)
+	}
+
+	return billed.Seconds()
+}
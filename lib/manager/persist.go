@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"log"
+	"start-my-game/lib/cloud"
+	"start-my-game/lib/state"
+)
+
+// Writes everything needed to continue after a restart to the state store
+func (manager *Manager) persist() {
+	manager.mutex.RLock()
+	serverId := 0
+	if manager.activeServer != nil {
+		serverId = manager.activeServer.Id
+	}
+	lastActivePlayer := manager.lastActivePlayer
+	// The startup is updated in place, so its values are copied
+	var startup *StartupProgress
+	if manager.startup != nil {
+		copied := *manager.startup
+		startup = &copied
+	}
+	budgetMonth, budgetSeconds, budgetAccruedAt := manager.budgetMonth, manager.budgetSeconds, manager.budgetAccruedAt
+	manager.mutex.RUnlock()
+
+	err := manager.store.Update(func(stored *state.State) {
+		stored.ServerId = serverId
+		stored.LastActivePlayer = lastActivePlayer
+		stored.Lifecycle = string(manager.lifecycle.State())
+		stored.BudgetMonth = budgetMonth
+		stored.BudgetSeconds = budgetSeconds
+		stored.BudgetAccruedAt = budgetAccruedAt
+		if startup != nil {
+			stored.StartupCurrent = startup.Current
+			stored.StartupMax = startup.Max
+		}
+	})
+
+	if err != nil {
+		log.Println("Couldn't persist the state:", err)
+	}
+}
+
+// Restores the state stored before the last restart, if it belongs to the current server.
+// The active server must be updated before calling this method.
+func (manager *Manager) restore() {
+	stored := manager.store.Get()
+	server := manager.activeServer
+	previous := State(stored.Lifecycle)
+
+	if stored.BudgetMonth != "" {
+		manager.budgetMonth = stored.BudgetMonth
+		manager.budgetSeconds = stored.BudgetSeconds
+		// The server was billed while the application wasn't running. If it was deleted in the
+		// meantime, the time of the deletion is unknown and the whole time is booked to be safe.
+		manager.budgetAccruedAt = stored.BudgetAccruedAt
+		manager.accrueBudget(server != nil)
+	}
+
+	initial := StateOff
+	if server != nil {
+		switch server.Status {
+		case cloud.StatusActive:
+			initial = StateRunning
+		case cloud.StatusStartup:
+			initial = StateBooting
+		}
+	}
+
+	if server == nil || server.Id != stored.ServerId {
+		if server == nil && previous == StateError {
+			initial = StateError
+		}
+
+		manager.lifecycle = newLifecycle(initial)
+		return
+	}
+
+	if !stored.LastActivePlayer.IsZero() {
+		manager.lastActivePlayer = stored.LastActivePlayer
+	}
+
+	if stored.StartupMax > 0 {
+		manager.startup = &StartupProgress{
+			Current: stored.StartupCurrent,
+			Max:     stored.StartupMax,
+		}
+	}
+
+	switch server.Status {
+	case cloud.StatusActive:
+		switch previous {
+		case StateRunning, StateIdleWarning, StateStopping, StateError:
+			initial = previous
+		case StateCreating, StateBooting, StateWaitingForGame:
+			initial = StateWaitingForGame
+		case StateDestroying:
+			initial = StateStopping
+		}
+	case cloud.StatusOff:
+		switch previous {
+		case StateStopping, StateDestroying:
+			initial = StateDestroying
+		case StateError:
+			initial = previous
+		}
+	}
+
+	log.Printf("Restored the state %v of server %v (previously %v)\n", initial, server.Name, previous)
+	manager.lifecycle = newLifecycle(initial)
+}
+
+// Continues an operation, which was interrupted by a restart of the application
+func (manager *Manager) resume() {
+	server := manager.getActiveServer()
+	if server == nil {
+		return
+	}
+
+	switch manager.lifecycle.State() {
+	case StateBooting, StateWaitingForGame:
+		if !manager.beginOperation() {
+			return
+		}
+		defer manager.endOperation()
+
+		log.Printf("Resuming the startup of server %v\n", server.Name)
+
+		manager.mutex.Lock()
+		if manager.startup == nil {
+			// Showing a startup bar, if the app and the server are starting
+			manager.startup = &StartupProgress{
+				Current: 3,
+				Max:     5,
+			}
+		}
+		manager.mutex.Unlock()
+
+		serverStartupCheck(manager, server)
+	case StateStopping, StateDestroying:
+		if !manager.beginOperation() {
+			return
+		}
+		defer manager.endOperation()
+
+		log.Printf("Resuming the deletion of server %v\n", server.Name)
+		manager.deleteServer()
+	}
+}
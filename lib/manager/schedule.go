@@ -0,0 +1,27 @@
+package manager
+
+import (
+	"log"
+	"time"
+)
+
+// Starts the server at the prewarm times of the schedule, even during quiet hours. Runs forever.
+func (manager *Manager) StartSchedule() {
+	if !manager.schedule.HasPrewarm() {
+		return
+	}
+
+	for {
+		next := manager.schedule.NextPrewarm(time.Now())
+		if next.IsZero() {
+			log.Println("There are no more scheduled starts")
+			return
+		}
+
+		log.Printf("The next scheduled start is at %v\n", next)
+		time.Sleep(time.Until(next))
+
+		status := manager.start("schedule")
+		log.Printf("Scheduled start of the server: %v\n", status)
+	}
+}
@@ -1,22 +1,20 @@
 package manager
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"start-my-game/lib/cloud"
-	"start-my-game/lib/gmod"
 	"time"
 )
 
+// The steps of the current or last startup, the state is kept by the lifecycle
 type StartupProgress struct {
-	start   time.Time
-	Current int
-	Max     int
-	Error   bool
-}
-
-func (progress *StartupProgress) InProgress() bool {
-	return progress.Current < progress.Max && !progress.Error
+	// Both are zero if the startup was restored after a restart
+	start     time.Time
+	stepStart time.Time
+	Current   int
+	Max       int
 }
 
 func (manager *Manager) UpdateActiveServer() {
@@ -25,26 +23,124 @@ func (manager *Manager) UpdateActiveServer() {
 	if err != nil {
 		if !cloud.IsNotExistsError(err) {
 			fmt.Println("Error during active server update:", err)
+		} else if manager.lifecycle != nil &&
+			(manager.lifecycle.State() == StateRunning || manager.lifecycle.State() == StateIdleWarning) {
+			// The server was deleted outside of the application, the lifecycle is nil until it's restored
+			err = manager.transition(StateOff, "server doesn't exist anymore")
+			if err != nil {
+				log.Println("Couldn't reset the state of the missing server:", err)
+			}
+			recordPlayers(0, 0)
 		}
-		manager.ActiveServer = nil
+		manager.setActiveServer(nil)
 	} else {
-		manager.ActiveServer = server
+		manager.setActiveServer(server)
 	}
 }
 
+// Creates or starts the server in the background, if it isn't running yet. The requester is
+// passed to the subscribers, if the request results in a start.
+// Returns one of 'already_running', 'in_startup', 'stopping', 'starting', 'creating', 'quiet_hours',
+// 'budget_exhausted' or 'failure'.
+func (manager *Manager) Start(requester string) string {
+	if quiet := manager.schedule.QuietHours(time.Now()); quiet != nil && quiet.RefuseStart {
+		return "quiet_hours"
+	}
+
+	return manager.start(requester)
+}
+
+func (manager *Manager) start(requester string) string {
+	if manager.budgetExhausted() {
+		return "budget_exhausted"
+	}
+
+	if !manager.beginOperation() {
+		if manager.lifecycle.InStartup() {
+			return "in_startup"
+		}
+
+		// The running operation is either a check or a deletion
+		return "stopping"
+	}
+
+	if manager.lifecycle.InStartup() {
+		manager.endOperation()
+		return "in_startup"
+	}
+
+	manager.UpdateActiveServer()
+	server := manager.getActiveServer()
+
+	if server == nil {
+		if !manager.canStart(StateCreating) {
+			manager.endOperation()
+			return "failure"
+		}
+
+		manager.publish(Event{Type: EventStartRequested, Requester: requester})
+		go func() {
+			defer manager.endOperation()
+			manager.createServer()
+		}()
+		return "creating"
+	}
+
+	if server.Status == cloud.StatusActive {
+		manager.endOperation()
+		return "already_running"
+	}
+
+	if !manager.canStart(StateBooting) {
+		manager.endOperation()
+		return "failure"
+	}
+
+	manager.publish(Event{Type: EventStartRequested, Requester: requester})
+	go func() {
+		defer manager.endOperation()
+		manager.startServer()
+	}()
+	return "starting"
+}
+
+// Whether the lifecycle can switch to the first state of the startup, the request would be
+// reported as successful otherwise
+func (manager *Manager) canStart(to State) bool {
+	state := manager.lifecycle.State()
+	if !canTransition(state, to) {
+		log.Printf("Won't start the server in state %v\n", state)
+		return false
+	}
+
+	return true
+}
+
 // UpdateActiveServer should be called before running this method
 func (manager *Manager) CreateServer() {
+	if !manager.beginOperation() {
+		log.Println("Won't create a new server while another operation is running")
+		return
+	}
+	defer manager.endOperation()
 
-	manager.Startup = &StartupProgress{
-		start:   time.Now(),
-		Current: 0,
-		Max:     5,
-		Error:   false,
+	manager.createServer()
+}
+
+// The operation must be started before calling this method
+func (manager *Manager) createServer() {
+
+	err := manager.transition(StateCreating, "server creation requested")
+	if err != nil {
+		log.Println("Won't create a new server:", err)
+		return
 	}
 
-	if manager.ActiveServer != nil && manager.ActiveServer.Status != cloud.StatusDestroyed {
+	manager.setStartup(5)
+
+	if server := manager.getActiveServer(); server != nil && server.Status != cloud.StatusDestroyed {
 		startupError(manager, fmt.Errorf("won't create a new server because there's a server with status %v",
-			manager.ActiveServer.Status))
+			server.Status))
 		return
 	}
 
@@ -57,7 +153,7 @@ func (manager *Manager) CreateServer() {
 		return
 	}
 
-	startupNext(manager)
+	startupNext(manager, "ssh_key")
 
 	// Get the snapshot id
 	snapshot, err := manager.cloud.GetSnapshot(manager.config.Cloud.Snapshot)
@@ -66,7 +162,7 @@ func (manager *Manager) CreateServer() {
 		return
 	}
 
-	startupNext(manager)
+	startupNext(manager, "snapshot")
 
 	// Creating the server
 	log.Printf("Creating a new server with\n ssh key: '%v'\n snapshot '%v'\n machine '%v'\n region '%v'\n",
@@ -84,23 +180,43 @@ func (manager *Manager) CreateServer() {
 		return
 	}
 
-	startupNext(manager)
+	startupNext(manager, "create_server")
+	manager.setActiveServer(server)
 	log.Printf("Server '%v' got the IP %v\n", server.Name, server.Ip)
 
+	err = manager.transition(StateBooting, "server created")
+	if err != nil {
+		startupError(manager, err)
+		return
+	}
+
 	serverStartupCheck(manager, server)
 }
 
 // UpdateActiveServer should be called before running this method
 func (manager *Manager) StartServer() {
+	if !manager.beginOperation() {
+		log.Println("Won't start the server while another operation is running")
+		return
+	}
+	defer manager.endOperation()
+
+	manager.startServer()
+}
+
+// The operation must be started before calling this method
+func (manager *Manager) startServer() {
 
-	server := manager.ActiveServer
-	manager.Startup = &StartupProgress{
-		start:   time.Now(),
-		Current: 0,
-		Max:     3,
-		Error:   false,
+	server := manager.getActiveServer()
+
+	err := manager.transition(StateBooting, "server start requested")
+	if err != nil {
+		log.Println("Won't start the server:", err)
+		return
 	}
 
+	manager.setStartup(3)
+
 	if server == nil {
 		startupError(manager, fmt.Errorf("can't start a non existing server"))
 		return
@@ -111,29 +227,36 @@ func (manager *Manager) StartServer() {
 		return
 	}
 
-	err := manager.cloud.StartServer(server)
+	err = manager.cloud.StartServer(server)
 	if err != nil {
 		startupError(manager, err)
 		return
 	}
 
-	startupNext(manager)
+	startupNext(manager, "start_server")
 	serverStartupCheck(manager, server)
 }
 
+// Waits until the server is booted, if the lifecycle is in StateBooting, and until the game is ready
 func serverStartupCheck(manager *Manager, server *cloud.Server) {
-	// Waiting 5 minutes for server boot; Checking every 30 seconds
-	online := false
-	for i := 0; i < 10; i++ {
+	if manager.lifecycle.State() == StateBooting && !waitForBoot(manager, server) {
+		return
+	}
 
-		server, err := manager.cloud.GetServer(manager.config.Cloud.ServerName)
-		if err != nil {
-			log.Println("Error while server boot check:", err)
-			continue
-		}
+	// waitForBoot stores the booted server, its IP may have been assigned while booting
+	if booted := manager.getActiveServer(); booted != nil {
+		server = booted
+	}
 
-		if server.Status != cloud.StatusActive {
-			time.Sleep(30 * time.Second)
+	// Waiting 5 minutes for the game server start
+	online := false
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), gameTimeout)
+		err := manager.game.Probe(ctx, server.Ip)
+		cancel()
+		if err != nil {
+			probeFailures.Inc("startup")
+			time.Sleep(gameCheckInterval)
 			continue
 		}
 
@@ -142,78 +265,239 @@ func serverStartupCheck(manager *Manager, server *cloud.Server) {
 	}
 
 	if !online {
-		startupError(manager, fmt.Errorf("server not online after 5 minutes"))
+		startupError(manager, fmt.Errorf("%v not responding after 5 mintues", manager.game.GetType()))
 		return
 	}
 
-	log.Printf("Server '%v' is online, waiting for gmod...\n", server.Name)
+	log.Printf("The %v server is online, everything was successful!", manager.game.GetType())
 
-	manager.ActiveServer = server
-	startupNext(manager)
+	startupNext(manager, "game")
+	manager.recordStartup()
+	manager.UpdateActiveServer()
+	manager.setLastActivePlayer(time.Now())
+
+	err := manager.transition(StateRunning, fmt.Sprintf("%v is ready", manager.game.GetType()))
+	if err != nil {
+		log.Println("Error after server startup:", err)
+	}
+}
+
+func waitForBoot(manager *Manager, server *cloud.Server) bool {
+	// Checking every 30 seconds until the provider specific timeout is reached
+	timeout := cloud.BootTimeout(manager.cloud)
+	deadline := time.Now().Add(timeout)
+	online := false
+	for {
+
+		current, err := manager.cloud.GetServer(manager.config.Cloud.ServerName)
+		if err == nil && current.Status == cloud.StatusActive {
+			// Some providers assign the IP while the server boots
+			server = current
+			online = true
+			break
+		}
 
-	// Waiting 5 minutes for the gmod server start
-	online = false
-	for i := 0; i < 20; i++ {
-		_, err := gmod.NewRcon(server.Ip, manager.config)
 		if err != nil {
-			time.Sleep(15 * time.Second)
-			continue
+			log.Println("Error while server boot check:", err)
 		}
 
-		online = true
+		if time.Now().Add(bootCheckInterval).After(deadline) {
+			break
+		}
+		time.Sleep(bootCheckInterval)
 	}
 
 	if !online {
-		startupError(manager, fmt.Errorf("gmod rcon not responding after 5 mintues"))
-		return
+		startupError(manager, fmt.Errorf("server not online after %v", timeout))
+		return false
 	}
 
-	log.Printf("Gmod is online, everything was successful!")
+	log.Printf("Server '%v' is online, waiting for %v...\n", server.Name, manager.game.GetType())
 
-	startupNext(manager)
-	manager.UpdateActiveServer()
-	manager.LastActivePlayer = time.Now()
+	manager.setActiveServer(server)
+	startupNext(manager, "boot")
+
+	err := manager.transition(StateWaitingForGame, "server is active")
+	if err != nil {
+		startupError(manager, err)
+		return false
+	}
+
+	return true
 }
 
 func startupError(manager *Manager, err error) {
-	manager.Startup.Error = true
 	log.Println("Error while server startup:", err)
+	_ = manager.transition(StateError, err.Error())
 }
 
-func startupNext(manager *Manager) {
-	manager.Startup.Current++
+// Marks the step as finished and records its duration
+func startupNext(manager *Manager, step string) {
+	now := time.Now()
+
+	manager.mutex.Lock()
+	startup := manager.startup
+	startup.Current++
+	stepStart := startup.stepStart
+	startup.stepStart = now
+	manager.mutex.Unlock()
+
+	if !stepStart.IsZero() {
+		startupStepDuration.Observe(now.Sub(stepStart).Seconds(), step)
+	}
+
+	manager.changed()
 }
 
-// UpdateActiveServer should be called before running this method
+func (manager *Manager) recordStartup() {
+	manager.mutex.RLock()
+	start := manager.startup.start
+	manager.mutex.RUnlock()
+
+	if !start.IsZero() {
+		startupDuration.Observe(time.Since(start).Seconds())
+	}
+}
+
+func (manager *Manager) setStartup(steps int) {
+	manager.mutex.Lock()
+	now := time.Now()
+	manager.startup = &StartupProgress{
+		start:     now,
+		stepStart: now,
+		Current:   0,
+		Max:       steps,
+	}
+	manager.mutex.Unlock()
+
+	manager.changed()
+}
+
+type deleteOptions struct {
+	// Shuts down the game and the server before destroying it
+	graceful bool
+	snapshot bool
+}
+
+// Stops and destroys the server in the background, optionally taking a snapshot before.
+// Returns one of 'not_running', 'in_startup', 'busy', 'failure' or 'stopping'.
+func (manager *Manager) Stop(snapshot bool) string {
+	return manager.stop(deleteOptions{graceful: true, snapshot: snapshot})
+}
+
+// Destroys the server in the background without shutting it down.
+// Returns one of 'not_running', 'in_startup', 'busy', 'failure' or 'stopping'.
+func (manager *Manager) Destroy() string {
+	return manager.stop(deleteOptions{graceful: false, snapshot: false})
+}
+
+func (manager *Manager) stop(options deleteOptions) string {
+	if !manager.beginOperation() {
+		if manager.lifecycle.InStartup() {
+			return "in_startup"
+		}
+
+		return "busy"
+	}
+
+	if manager.lifecycle.InStartup() {
+		manager.endOperation()
+		return "in_startup"
+	}
+
+	manager.UpdateActiveServer()
+	server := manager.getActiveServer()
+	if server == nil {
+		manager.endOperation()
+		return "not_running"
+	}
+
+	// Checking the first transition of the deletion, otherwise the request would be reported as stopping
+	next := StateDestroying
+	if options.snapshot || (options.graceful && server.Status == cloud.StatusActive) {
+		next = StateStopping
+	}
+	if state := manager.lifecycle.State(); state != next && !canTransition(state, next) {
+		log.Printf("Won't delete the server in state %v\n", state)
+		manager.endOperation()
+		return "failure"
+	}
+
+	go func() {
+		defer manager.endOperation()
+		manager.deleteServerWith(options)
+	}()
+	return "stopping"
+}
+
+// The operation must be started and UpdateActiveServer should be called before running this method
 func (manager *Manager) deleteServer() {
-	server := manager.ActiveServer
-	manager.ActiveServer = nil
+	manager.deleteServerWith(deleteOptions{
+		graceful: true,
+		snapshot: manager.config.Cloud.SnapshotOnShutdown,
+	})
+}
+
+// The operation must be started and UpdateActiveServer should be called before running this method
+func (manager *Manager) deleteServerWith(options deleteOptions) {
+	server := manager.getActiveServer()
 
 	if server.Status == cloud.StatusDestroyed {
 		log.Println("Won't delete a destroyed server")
+		manager.setActiveServer(nil)
 		return
 	}
 
 	// Gracefully stopping the server if online
-	if server.Status == cloud.StatusActive {
-		err := manager.cloud.StopServer(server)
+	if options.graceful && server.Status == cloud.StatusActive {
+		err := manager.ensureState(StateStopping, "stopping the server")
+		if err != nil {
+			log.Println("Won't stop the server:", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), gameTimeout)
+		err = manager.game.Shutdown(ctx, server.Ip)
+		cancel()
+		if err != nil {
+			log.Printf("Couldn't prepare %v for the shutdown: %v\n", manager.game.GetType(), err)
+		}
+
+		err = manager.cloud.StopServer(server)
 		if err != nil {
 			log.Println("Couldn't stop server", err)
 		}
 
 		log.Println("Stopping the server", server.Name)
 
-		time.Sleep(time.Duration(30 * time.Second))
+		time.Sleep(shutdownWait)
+	}
+
+	_ = manager.game.Close()
+
+	if options.snapshot && !manager.snapshotServer(server) {
+		return
+	}
+
+	err := manager.ensureState(StateDestroying, "destroying the server")
+	if err != nil {
+		log.Println("Won't destroy the server:", err)
+		return
 	}
 
 	log.Printf("Destroying server %v...\n", server.Name)
 	// Deleting the virtual server instance
-	err := manager.cloud.DestroyServer(server)
+	err = manager.cloud.DestroyServer(server)
 	if err != nil {
-		log.Panicln("Couldn't destroy server:", err)
+		log.Println("Couldn't destroy server:", err)
+		_ = manager.transition(StateError, err.Error())
+		return
 	}
 
 	server.Status = cloud.StatusDestroyed
+	manager.setActiveServer(nil)
+	recordPlayers(0, 0)
 
 	log.Println("Destroyed server", server.Name)
+	_ = manager.transition(StateOff, "server destroyed")
 }
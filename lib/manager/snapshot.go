@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"log"
+	"sort"
+	"start-my-game/lib/cloud"
+	"start-my-game/lib/config"
+	"strings"
+	"time"
+)
+
+// Takes a snapshot of the stopped server, which will be used for the next creation.
+// Returns false if the server must not be destroyed, because the snapshot failed.
+func (manager *Manager) snapshotServer(server *cloud.Server) bool {
+	err := manager.ensureState(StateStopping, "taking a snapshot")
+	if err != nil {
+		log.Println("Won't take a snapshot:", err)
+		return false
+	}
+
+	name := cloud.SnapshotName(manager.config.Cloud.Snapshot, time.Now())
+	log.Printf("Taking snapshot '%v' of server %v...\n", name, server.Name)
+
+	snapshot, err := manager.cloud.CreateSnapshot(server, name)
+	if err != nil {
+		// Keeping the server, otherwise the progress since the last snapshot would be lost
+		log.Println("Couldn't take a snapshot, won't destroy the server:", err)
+		_ = manager.transition(StateError, err.Error())
+		return false
+	}
+
+	log.Printf("Took snapshot '%v' of server %v\n", snapshot.Name, server.Name)
+
+	if manager.config.Cloud.Retention != nil {
+		manager.enforceRetention(*manager.config.Cloud.Retention)
+	}
+
+	return true
+}
+
+// Deletes the snapshots taken on shutdown, which aren't kept by the retention policy
+func (manager *Manager) enforceRetention(retention config.Retention) {
+	name := manager.config.Cloud.Snapshot
+
+	snapshots, err := manager.cloud.ListSnapshots(name)
+	if err != nil {
+		log.Println("Couldn't list snapshots for the retention:", err)
+		return
+	}
+
+	for _, snapshot := range expiredSnapshots(snapshots, name, retention, time.Now()) {
+		log.Printf("Deleting snapshot '%v' because of the retention policy\n", snapshot.Name)
+
+		err := manager.cloud.DeleteSnapshot(snapshot)
+		if err != nil {
+			log.Printf("Couldn't delete snapshot '%v': %v\n", snapshot.Name, err)
+		}
+	}
+}
+
+// Returns the snapshots, which aren't kept by any rule. The newest snapshot is never returned,
+// because it's used for the next creation, and neither is the manually created one with the
+// exact base name.
+func expiredSnapshots(snapshots []*cloud.Snapshot, base string, retention config.Retention, now time.Time) []*cloud.Snapshot {
+	var sorted []*cloud.Snapshot
+	for _, snapshot := range snapshots {
+		if !strings.EqualFold(snapshot.Name, base) {
+			sorted = append(sorted, snapshot)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Created.After(sorted[j].Created)
+	})
+
+	keptDays := make(map[string]bool)
+	dailyLimit := now.AddDate(0, 0, -retention.KeepDailyDays)
+
+	var expired []*cloud.Snapshot
+
+	for i, snapshot := range sorted {
+		// The newest snapshot of a day is kept as the daily one, even if it's also kept by another rule
+		day := snapshot.Created.Format("2006-01-02")
+		daily := snapshot.Created.After(dailyLimit) && !keptDays[day]
+		if daily {
+			keptDays[day] = true
+		}
+
+		if i == 0 || i < retention.KeepLast || daily {
+			continue
+		}
+
+		expired = append(expired, snapshot)
+	}
+
+	return expired
+}
@@ -0,0 +1,88 @@
+package manager
+
+import (
+	"sort"
+	"start-my-game/lib/cloud"
+	"start-my-game/lib/config"
+	"testing"
+	"time"
+)
+
+func TestExpiredSnapshots(t *testing.T) {
+	now := time.Date(2020, 12, 10, 12, 0, 0, 0, time.UTC)
+	at := func(day int, hour int) time.Time {
+		return time.Date(2020, 12, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	for _, test := range []struct {
+		name      string
+		snapshots map[string]time.Time
+		retention config.Retention
+		expired   []string
+	}{
+		{
+			name: "keep last with the base snapshot as oldest",
+			snapshots: map[string]time.Time{
+				"gmod": at(1, 10), "gmod 1": at(7, 10), "gmod 2": at(8, 10), "gmod 3": at(9, 10),
+			},
+			retention: config.Retention{KeepLast: 2},
+			expired:   []string{"gmod 1"},
+		},
+		{
+			name: "keep last with the base snapshot as newest",
+			snapshots: map[string]time.Time{
+				"gmod 1": at(7, 10), "gmod 2": at(8, 10), "gmod 3": at(9, 10), "GMOD": at(10, 10),
+			},
+			retention: config.Retention{KeepLast: 2},
+			expired:   []string{"gmod 1"},
+		},
+		{
+			name: "the newest one is always kept",
+			snapshots: map[string]time.Time{
+				"gmod": at(10, 10), "gmod 1": at(7, 10), "gmod 2": at(8, 10),
+			},
+			retention: config.Retention{},
+			expired:   []string{"gmod 1"},
+		},
+		{
+			name: "the newest one of a day is kept",
+			snapshots: map[string]time.Time{
+				"gmod": at(1, 10), "gmod 1": at(2, 10), "gmod 2": at(8, 10), "gmod 3": at(8, 20),
+				"gmod 4": at(9, 8), "gmod 5": at(9, 22),
+			},
+			retention: config.Retention{KeepDailyDays: 3},
+			expired:   []string{"gmod 1", "gmod 2", "gmod 4"},
+		},
+		{
+			name: "both rules",
+			snapshots: map[string]time.Time{
+				"gmod": at(1, 10), "gmod 1": at(2, 10), "gmod 2": at(3, 10), "gmod 3": at(9, 8),
+				"gmod 4": at(9, 9), "gmod 5": at(9, 10),
+			},
+			retention: config.Retention{KeepLast: 3, KeepDailyDays: 7},
+			expired:   []string{"gmod 1", "gmod 2"},
+		},
+	} {
+		var snapshots []*cloud.Snapshot
+		for name, created := range test.snapshots {
+			snapshots = append(snapshots, &cloud.Snapshot{Name: name, Created: created})
+		}
+
+		var expired []string
+		for _, snapshot := range expiredSnapshots(snapshots, "gmod", test.retention, now) {
+			expired = append(expired, snapshot.Name)
+		}
+		sort.Strings(expired)
+
+		if len(expired) != len(test.expired) {
+			t.Errorf("%v: got expired snapshots %v, expected %v", test.name, expired, test.expired)
+			continue
+		}
+		for i := range expired {
+			if expired[i] != test.expired[i] {
+				t.Errorf("%v: got expired snapshots %v, expected %v", test.name, expired, test.expired)
+				break
+			}
+		}
+	}
+}
@@ -2,24 +2,112 @@ package manager
 
 import (
 	"start-my-game/lib/cloud"
+	"start-my-game/lib/game"
+	"time"
 )
 
-// Can be one of 'off', 'setup', 'setup_error', 'active'
-func (manager *Manager) GetServerStatus() string {
-	if manager.Startup != nil && manager.Startup.Error {
-		return "startup_error"
+// A consistent copy of the manager state, which can be used without locking
+type Status struct {
+	// Can be one of 'off', 'startup', 'startup_error', 'active' or 'stopping'
+	ServerStatus string
+	State        State
+	StateSince   time.Time
+	// Nil if there's no server
+	Server *cloud.Server
+	// Nil if the server wasn't started by this application
+	Startup          *StartupProgress
+	LastActivePlayer time.Time
+	// Nil if the game server wasn't checked yet
+	LastGameInfo *game.ServerInfo
+}
+
+func (manager *Manager) Status() Status {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	state, since := manager.lifecycle.Current()
+
+	status := Status{
+		ServerStatus:     serverStatus(state),
+		State:            state,
+		StateSince:       since,
+		LastActivePlayer: manager.lastActivePlayer,
 	}
 
-	if manager.ActiveServer == nil {
-		return cloud.StatusOff
+	if manager.activeServer != nil {
+		server := *manager.activeServer
+		status.Server = &server
 	}
 
-	switch manager.ActiveServer.Status {
-	case cloud.StatusActive:
-		return cloud.StatusActive
-	case cloud.StatusStartup:
+	if manager.startup != nil {
+		startup := *manager.startup
+		status.Startup = &startup
+	}
+
+	if manager.lastGameInfo != nil {
+		info := *manager.lastGameInfo
+		status.LastGameInfo = &info
+	}
+
+	return status
+}
+
+// Can be one of 'off', 'startup', 'startup_error', 'active' or 'stopping'
+func (manager *Manager) GetServerStatus() string {
+	return serverStatus(manager.lifecycle.State())
+}
+
+func serverStatus(state State) string {
+	switch state {
+	case StateCreating, StateBooting, StateWaitingForGame:
 		return cloud.StatusStartup
+	case StateRunning, StateIdleWarning:
+		return cloud.StatusActive
+	case StateStopping, StateDestroying:
+		return "stopping"
+	case StateError:
+		return "startup_error"
 	default:
 		return cloud.StatusOff
 	}
 }
+
+// A copy of the active server or nil
+func (manager *Manager) getActiveServer() *cloud.Server {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	if manager.activeServer == nil {
+		return nil
+	}
+
+	server := *manager.activeServer
+	return &server
+}
+
+func (manager *Manager) setActiveServer(server *cloud.Server) {
+	manager.mutex.Lock()
+	if manager.activeServer != nil && server == nil {
+		manager.priceFailed = false
+	}
+	manager.activeServer = server
+	manager.recordBilling(server)
+	manager.mutex.Unlock()
+
+	manager.changed()
+}
+
+func (manager *Manager) getLastActivePlayer() time.Time {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.lastActivePlayer
+}
+
+func (manager *Manager) setLastActivePlayer(time time.Time) {
+	manager.mutex.Lock()
+	manager.lastActivePlayer = time
+	manager.mutex.Unlock()
+
+	manager.changed()
+}
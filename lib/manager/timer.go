@@ -4,42 +4,144 @@ import (
 	"log"
 	"start-my-game/lib/cloud"
 	"start-my-game/lib/config"
-	"start-my-game/lib/gmod"
+	"start-my-game/lib/game"
+	"start-my-game/lib/metrics"
+	"start-my-game/lib/schedule"
+	"start-my-game/lib/state"
+	"sync"
 	"time"
 )
 
+// Maximum duration of a single request to the game server including a possible reconnect
+const gameTimeout = 15 * time.Second
+
+// The waiting times of the operations, the tests shorten them
+var (
+	// Interval of the status checks while the server boots
+	bootCheckInterval = 30 * time.Second
+	// Interval of the checks until the game is ready, it's checked 20 times
+	gameCheckInterval = 15 * time.Second
+	// Time the server gets to shut down before it's destroyed
+	shutdownWait = 30 * time.Second
+)
+
+// The manager is safe for concurrent use. Creating, starting and deleting the server are
+// operations, of which only one can run at the same time.
 type Manager struct {
-	LastActivePlayer time.Time
-	LastRconInfo     *gmod.GServerInfo
-	ActiveServer     *cloud.Server
-	Startup          *StartupProgress
-	config           *config.Config
-	cloud            cloud.Cloud
+	config    *config.Config
+	cloud     cloud.Cloud
+	game      game.Adapter
+	lifecycle *Lifecycle
+	store     *state.Store
+	events    *broker
+	schedule  *schedule.Schedule
+
+	// Guards the following fields
+	mutex            sync.RWMutex
+	operation        bool
+	lastActivePlayer time.Time
+	lastGameInfo     *game.ServerInfo
+	activeServer     *cloud.Server
+	startup          *StartupProgress
+	// The time the server exists is accumulated in billed
+	billed      time.Duration
+	billedSince time.Time
+	// The time the server existed in the current month, see accrueBudget
+	budgetMonth     string
+	budgetSeconds   float64
+	budgetAccruedAt time.Time
+	// Cached after it was read from the cloud
+	hourlyPrice float64
+	priceKnown  bool
+	// The price couldn't be read, it's tried again after the server was deleted
+	priceFailed bool
+	// Logs the missing price only once
+	priceWarning sync.Once
 }
 
 func (manager *Manager) interval() time.Duration {
-	return time.Duration(manager.config.Gmod.CheckInterval) * time.Minute
+	return time.Duration(manager.config.Game.CheckInterval) * time.Minute
 }
 
+// Quiet hours can shorten the delay
 func (manager *Manager) shutdownDelay() time.Duration {
-	return time.Duration(manager.config.Gmod.ShutdownAfter) * time.Minute
+	if quiet := manager.schedule.QuietHours(time.Now()); quiet != nil && quiet.ShutdownAfter > 0 {
+		return time.Duration(quiet.ShutdownAfter) * time.Minute
+	}
+
+	return time.Duration(manager.config.Game.ShutdownAfter) * time.Minute
 }
 
-func NewManager(cfg *config.Config, acloud cloud.Cloud) *Manager {
+func NewManager(cfg *config.Config, acloud cloud.Cloud, adapter game.Adapter, store *state.Store,
+	sched *schedule.Schedule) *Manager {
 	manager := Manager{
-		LastActivePlayer: time.Time{},
-		LastRconInfo:     nil,
-		ActiveServer:     nil,
-		config:           cfg,
-		cloud:            acloud,
+		config:   cfg,
+		cloud:    acloud,
+		game:     adapter,
+		store:    store,
+		events:   newBroker(),
+		schedule: sched,
 	}
 
-	manager.LastActivePlayer = time.Now().Add(manager.shutdownDelay() / -2)
+	manager.lastActivePlayer = time.Now().Add(manager.shutdownDelay() / -2)
 	manager.UpdateActiveServer()
+	manager.restore()
+	recordState(manager.lifecycle.State())
+
+	metrics.NewCounterFunc("smg_server_billed_seconds_total",
+		"Seconds a server existed at the cloud provider since the start of the application", manager.billedSeconds)
 
 	return &manager
 }
 
+func (manager *Manager) Lifecycle() *Lifecycle {
+	return manager.lifecycle
+}
+
+// Switches to the next state and logs the transition
+func (manager *Manager) transition(to State, reason string) error {
+	transition, err := manager.lifecycle.Transition(to, reason)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Server state changed from %v to %v: %v\n", transition.From, transition.To, transition.Reason)
+	recordState(transition.To)
+	manager.publish(Event{Type: EventTransition, Transition: &transition})
+	manager.changed()
+
+	return nil
+}
+
+// Like transition, but doesn't fail if the lifecycle already is in the state
+func (manager *Manager) ensureState(to State, reason string) error {
+	if manager.lifecycle.State() == to {
+		return nil
+	}
+
+	return manager.transition(to, reason)
+}
+
+// Returns false if another operation is running, otherwise endOperation must be called afterwards
+func (manager *Manager) beginOperation() bool {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	if manager.operation {
+		return false
+	}
+
+	manager.operation = true
+	return true
+}
+
+func (manager *Manager) endOperation() {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.operation = false
+}
+
 func (manager *Manager) DelayCheckStart() {
 	fullInterval := time.Now().Truncate(manager.interval()).Add(manager.interval())
 
@@ -50,25 +152,15 @@ func (manager *Manager) DelayCheckStart() {
 }
 
 func (manager *Manager) StartCheck() {
-	manager.UpdateActiveServer()
-	if manager.ActiveServer == nil {
+	server := manager.getActiveServer()
+
+	if server == nil {
 		log.Println("At the beginning there was nothing")
 	} else {
-		server := manager.ActiveServer
 		log.Printf("At the begining there was a server with the name %v, the ip %v. It was very %v.\n",
 			server.Name, server.Ip, server.Status)
 
-		if manager.ActiveServer.Status == cloud.StatusStartup {
-			// Showing a startup bar, if the app and the server are starting
-			manager.Startup = &StartupProgress{
-				start:   time.Now(),
-				Current: 3,
-				Max:     5,
-				Error:   false,
-			}
-			manager.ActiveServer = nil
-			serverStartupCheck(manager, server)
-		}
+		manager.resume()
 	}
 
 	timer := time.NewTicker(manager.interval())
@@ -81,39 +173,67 @@ func (manager *Manager) StartCheck() {
 }
 
 func (manager *Manager) check() {
-	if manager.ActiveServer == nil {
+	// The server could have been deleted outside of the application, the running operation
+	// updates the server itself
+	if manager.beginOperation() {
+		manager.UpdateActiveServer()
+		manager.endOperation()
+	}
+
+	server := manager.getActiveServer()
+	if server == nil {
+		return
+	}
+
+	if manager.lifecycle.InStartup() {
 		return
 	}
 
-	if manager.Startup != nil && manager.Startup.InProgress() {
+	if manager.budgetExhausted() {
+		manager.shutdownForBudget(server)
 		return
 	}
 
-	if manager.ActiveServer.Status == cloud.StatusActive {
-		rcon, err := gmod.NewRcon(manager.ActiveServer.Ip, manager.config)
+	if server.Status == cloud.StatusActive {
+		gameInfo, err := manager.updateGameInfo(server)
 		if err != nil {
-			log.Println("Couldn't connect via rcon to the server:", err)
+			log.Println("Couldn't read online players:", err)
 			return
 		}
 
-		rconInfo, err := rcon.ServerStatus()
-		if err != nil {
-			log.Println("Couldn't read online players via rcon", err)
+		if gameInfo.Online > 0 {
+			log.Printf("%v of %v players online\n", gameInfo.Online, gameInfo.Max)
+
+			if manager.lifecycle.State() == StateIdleWarning {
+				manager.warnIdle(StateRunning, "players are online again")
+			}
 			return
 		}
 
-		manager.LastRconInfo = rconInfo
-
-		if rconInfo.Online > 0 {
-			log.Printf("%v of %v players online\n", rconInfo.Online, rconInfo.Max)
-			manager.LastActivePlayer = time.Now()
+		emptyDuration := time.Since(manager.getLastActivePlayer())
+		state := manager.lifecycle.State()
+		if (state == StateRunning || state == StateIdleWarning) &&
+			emptyDuration >= manager.shutdownDelay()-manager.warningDuration() &&
+			!manager.warnBeforeShutdown(server) {
 			return
 		}
 	}
 
-	emptyDuration := time.Since(manager.LastActivePlayer)
+	emptyDuration := time.Since(manager.getLastActivePlayer())
 	// log.Printf("Empty Duration: %v ShutdownDelay: %v", emptyDuration.Seconds(), manager.shutdownDelay().Seconds())
 	if emptyDuration.Seconds() >= manager.shutdownDelay().Seconds() {
+		if !manager.beginOperation() {
+			return
+		}
+		defer manager.endOperation()
+
+		// The server could have been restarted or deleted since the beginning of the check
+		manager.UpdateActiveServer()
+		if manager.lifecycle.InStartup() || manager.getActiveServer() == nil ||
+			time.Since(manager.getLastActivePlayer()) < manager.shutdownDelay() {
+			return
+		}
+
 		manager.deleteServer()
 	}
 }
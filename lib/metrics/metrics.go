@@ -0,0 +1,309 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The metrics of all packages are registered here and exposed by Handler
+var Default = NewRegistry()
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// Buckets in seconds for durations between a few milliseconds and ten minutes
+var DurationBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+// A collection of metrics, which can be written in the Prometheus text format
+type Registry struct {
+	mutex    sync.Mutex
+	families map[string]*family
+}
+
+// A metric with all its label combinations
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	// Only used by histograms
+	buckets []float64
+	// The value of metrics without labels can be read on demand
+	read func() float64
+
+	mutex   sync.Mutex
+	samples map[string]*sample
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+	// Only used by histograms, the counts aren't cumulative
+	counts []uint64
+	count  uint64
+}
+
+type Counter struct {
+	family *family
+}
+
+type Gauge struct {
+	family *family
+}
+
+type Histogram struct {
+	family *family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Panics if a metric with the name already exists, unless both read their value from a function
+func (registry *Registry) register(family *family) *family {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if existing, ok := registry.families[family.name]; ok {
+		if existing.read == nil || family.read == nil || existing.kind != family.kind {
+			log.Panicf("the metric %v is already registered\n", family.name)
+		}
+	}
+
+	family.samples = make(map[string]*sample)
+	registry.families[family.name] = family
+
+	return family
+}
+
+func (registry *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	return &Counter{registry.register(&family{name: name, help: help, kind: kindCounter, labels: labels})}
+}
+
+// The function must return a value which never decreases. It replaces the function of an
+// existing metric with the name.
+func (registry *Registry) NewCounterFunc(name string, help string, read func() float64) {
+	registry.register(&family{name: name, help: help, kind: kindCounter, read: read})
+}
+
+func (registry *Registry) NewGauge(name string, help string, labels ...string) *Gauge {
+	return &Gauge{registry.register(&family{name: name, help: help, kind: kindGauge, labels: labels})}
+}
+
+// Replaces the function of an existing metric with the name
+func (registry *Registry) NewGaugeFunc(name string, help string, read func() float64) {
+	registry.register(&family{name: name, help: help, kind: kindGauge, read: read})
+}
+
+// The buckets are the upper bounds in ascending order, the bucket +Inf is added automatically
+func (registry *Registry) NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{registry.register(&family{
+		name:    name,
+		help:    help,
+		kind:    kindHistogram,
+		labels:  labels,
+		buckets: buckets,
+	})}
+}
+
+func NewCounter(name string, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+func NewCounterFunc(name string, help string, read func() float64) {
+	Default.NewCounterFunc(name, help, read)
+}
+
+func NewGauge(name string, help string, labels ...string) *Gauge {
+	return Default.NewGauge(name, help, labels...)
+}
+
+func NewGaugeFunc(name string, help string, read func() float64) {
+	Default.NewGaugeFunc(name, help, read)
+}
+
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// The value must not be negative
+func (counter *Counter) Add(value float64, labelValues ...string) {
+	counter.family.update(labelValues, func(sample *sample) {
+		sample.value += value
+	})
+}
+
+func (counter *Counter) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+func (gauge *Gauge) Set(value float64, labelValues ...string) {
+	gauge.family.update(labelValues, func(sample *sample) {
+		sample.value = value
+	})
+}
+
+func (histogram *Histogram) Observe(value float64, labelValues ...string) {
+	family := histogram.family
+
+	family.update(labelValues, func(sample *sample) {
+		if sample.counts == nil {
+			sample.counts = make([]uint64, len(family.buckets))
+		}
+
+		for i, bound := range family.buckets {
+			if value <= bound {
+				sample.counts[i]++
+				break
+			}
+		}
+
+		sample.value += value
+		sample.count++
+	})
+}
+
+func (family *family) update(labelValues []string, update func(sample *sample)) {
+	if len(labelValues) != len(family.labels) {
+		log.Panicf("the metric %v needs %v label values, got %v\n", family.name, len(family.labels), len(labelValues))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	family.mutex.Lock()
+	defer family.mutex.Unlock()
+
+	current, ok := family.samples[key]
+	if !ok {
+		current = &sample{labelValues: append([]string(nil), labelValues...)}
+		family.samples[key] = current
+	}
+
+	update(current)
+}
+
+// Writes all metrics in the Prometheus text format
+func (registry *Registry) Write(writer io.Writer) error {
+	registry.mutex.Lock()
+	families := make([]*family, 0, len(registry.families))
+	for _, family := range registry.families {
+		families = append(families, family)
+	}
+	registry.mutex.Unlock()
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	buffered := bufio.NewWriter(writer)
+	for _, family := range families {
+		family.write(buffered)
+	}
+
+	return buffered.Flush()
+}
+
+func (family *family) write(writer *bufio.Writer) {
+	_, _ = fmt.Fprintf(writer, "# HELP %v %v\n", family.name, escapeHelp(family.help))
+	_, _ = fmt.Fprintf(writer, "# TYPE %v %v\n", family.name, family.kind)
+
+	if family.read != nil {
+		writeLine(writer, family.name, nil, nil, family.read())
+		return
+	}
+
+	family.mutex.Lock()
+	defer family.mutex.Unlock()
+
+	keys := make([]string, 0, len(family.samples))
+	for key := range family.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		sample := family.samples[key]
+
+		if family.kind != kindHistogram {
+			writeLine(writer, family.name, family.labels, sample.labelValues, sample.value)
+			continue
+		}
+
+		labels := append(append([]string(nil), family.labels...), "le")
+		cumulative := uint64(0)
+		for i, bound := range family.buckets {
+			cumulative += sample.counts[i]
+			values := append(append([]string(nil), sample.labelValues...), formatValue(bound))
+			writeLine(writer, family.name+"_bucket", labels, values, float64(cumulative))
+		}
+
+		values := append(append([]string(nil), sample.labelValues...), "+Inf")
+		writeLine(writer, family.name+"_bucket", labels, values, float64(sample.count))
+		writeLine(writer, family.name+"_sum", family.labels, sample.labelValues, sample.value)
+		writeLine(writer, family.name+"_count", family.labels, sample.labelValues, float64(sample.count))
+	}
+}
+
+func writeLine(writer *bufio.Writer, name string, labels []string, labelValues []string, value float64) {
+	_, _ = writer.WriteString(name)
+
+	if len(labels) > 0 {
+		_ = writer.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				_ = writer.WriteByte(',')
+			}
+			_, _ = fmt.Fprintf(writer, "%v=\"%v\"", label, escapeLabel(labelValues[i]))
+		}
+		_ = writer.WriteByte('}')
+	}
+
+	_, _ = fmt.Fprintf(writer, " %v\n", formatValue(value))
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+var helpReplacer = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+var labelReplacer = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\"", "\\\"")
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func escapeLabel(value string) string {
+	return labelReplacer.Replace(value)
+}
+
+// Serves the metrics of the default registry
+func Handler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		err := Default.Write(writer)
+		if err != nil {
+			log.Println("Couldn't write metrics:", err)
+		}
+	})
+}
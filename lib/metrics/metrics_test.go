@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	registry := NewRegistry()
+
+	requests := registry.NewCounter("test_requests_total", "Requests by path\nand \\ method", "path", "method")
+	requests.Inc("/start/", "POST")
+	requests.Add(2, "/a \"quoted\"\npath\\", "GET")
+
+	registry.NewGauge("test_players", "Players online").Set(3.5)
+	registry.NewGaugeFunc("test_uptime_seconds", "Seconds since the start", func() float64 { return 42 })
+
+	duration := registry.NewHistogram("test_duration_seconds", "Durations by step", []float64{0.5, 1, 5}, "step")
+	duration.Observe(0.25, "boot")
+	duration.Observe(1, "boot")
+	duration.Observe(7, "boot")
+	duration.Observe(0.5, "game")
+
+	// Registered without a sample
+	registry.NewCounter("test_errors_total", "Errors", "kind")
+
+	expected := `# HELP test_duration_seconds Durations by step
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{step="boot",le="0.5"} 1
+test_duration_seconds_bucket{step="boot",le="1"} 2
+test_duration_seconds_bucket{step="boot",le="5"} 2
+test_duration_seconds_bucket{step="boot",le="+Inf"} 3
+test_duration_seconds_sum{step="boot"} 8.25
+test_duration_seconds_count{step="boot"} 3
+test_duration_seconds_bucket{step="game",le="0.5"} 1
+test_duration_seconds_bucket{step="game",le="1"} 1
+test_duration_seconds_bucket{step="game",le="5"} 1
+test_duration_seconds_bucket{step="game",le="+Inf"} 1
+test_duration_seconds_sum{step="game"} 0.5
+test_duration_seconds_count{step="game"} 1
+# HELP test_errors_total Errors
+# TYPE test_errors_total counter
+# HELP test_players Players online
+# TYPE test_players gauge
+test_players 3.5
+# HELP test_requests_total Requests by path\nand \\ method
+# TYPE test_requests_total counter
+test_requests_total{path="/a \"quoted\"\npath\\",method="GET"} 2
+test_requests_total{path="/start/",method="POST"} 1
+# HELP test_uptime_seconds Seconds since the start
+# TYPE test_uptime_seconds gauge
+test_uptime_seconds 42
+`
+
+	var buffer bytes.Buffer
+	if err := registry.Write(&buffer); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if buffer.String() != expected {
+		t.Errorf("got the metrics\n%v\nexpected\n%v", buffer.String(), expected)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	registry := NewRegistry()
+
+	// Functions are replaced, e.g. by a second manager in the tests
+	registry.NewCounterFunc("test_func_total", "First", func() float64 { return 1 })
+	registry.NewCounterFunc("test_func_total", "Second", func() float64 { return 2 })
+
+	var buffer bytes.Buffer
+	_ = registry.Write(&buffer)
+	if !strings.Contains(buffer.String(), "test_func_total 2\n") {
+		t.Errorf("the function wasn't replaced:\n%v", buffer.String())
+	}
+
+	registry.NewCounter("test_total", "Test")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a metric twice didn't panic")
+		}
+	}()
+	registry.NewCounter("test_total", "Test")
+}
+
+func TestHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("got the content type %q", contentType)
+	}
+}
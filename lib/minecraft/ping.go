@@ -0,0 +1,257 @@
+package minecraft
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// https://wiki.vg/Server_List_Ping
+const (
+	packetHandshake int32 = 0x00
+	packetStatus    int32 = 0x00
+	// Any protocol version is accepted for the status request
+	protocolVersion int32 = -1
+	stateStatus     int32 = 1
+
+	// Used if the context has no deadline
+	pingTimeout = 5 * time.Second
+	// The status JSON including favicon never gets bigger than this
+	maxPacketSize = 1 << 21
+)
+
+type Status struct {
+	Version  string
+	Protocol int
+	Online   int
+	Max      int
+	Motd     string
+	// Only a sample of the online players, big servers don't list everyone
+	Players []string
+}
+
+type statusResponse struct {
+	Version struct {
+		Name     string `json:"name"`
+		Protocol int    `json:"protocol"`
+	} `json:"version"`
+	Players struct {
+		Max    int `json:"max"`
+		Online int `json:"online"`
+		Sample []struct {
+			Name string `json:"name"`
+		} `json:"sample"`
+	} `json:"players"`
+	Description json.RawMessage `json:"description"`
+}
+
+// A chat component, which is used for the description of newer servers
+type chatComponent struct {
+	Text  string            `json:"text"`
+	Extra []json.RawMessage `json:"extra"`
+}
+
+// Requests the status of a Java Edition server using the Server List Ping protocol
+func Ping(ctx context.Context, address string) (*Status, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address '%v': %v", address, err)
+	}
+
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port '%v': %v", portString, err)
+	}
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't connect to '%v': %v", address, err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(pingTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	var handshake bytes.Buffer
+	writeVarInt(&handshake, protocolVersion)
+	writeString(&handshake, host)
+	_ = binary.Write(&handshake, binary.BigEndian, uint16(port))
+	writeVarInt(&handshake, stateStatus)
+
+	err = writePacket(conn, packetHandshake, handshake.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't send handshake to '%v': %v", address, err)
+	}
+
+	err = writePacket(conn, packetStatus, nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't send status request to '%v': %v", address, err)
+	}
+
+	id, payload, err := readPacket(bufio.NewReader(conn))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read status of '%v': %v", address, err)
+	}
+
+	if id != packetStatus {
+		return nil, fmt.Errorf("unexpected packet 0x%x from '%v'", id, address)
+	}
+
+	jsonString, err := readString(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("invalid status of '%v': %v", address, err)
+	}
+
+	return parseStatus(jsonString)
+}
+
+func parseStatus(jsonString string) (*Status, error) {
+	response := statusResponse{}
+	err := json.Unmarshal([]byte(jsonString), &response)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse status: %v", err)
+	}
+
+	players := make([]string, 0, len(response.Players.Sample))
+	for _, player := range response.Players.Sample {
+		players = append(players, player.Name)
+	}
+
+	return &Status{
+		Version:  response.Version.Name,
+		Protocol: response.Version.Protocol,
+		Online:   response.Players.Online,
+		Max:      response.Players.Max,
+		Motd:     flattenDescription(response.Description),
+		Players:  players,
+	}, nil
+}
+
+// The description is either a plain string or a chat component with nested components
+func flattenDescription(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+
+	component := chatComponent{}
+	if json.Unmarshal(raw, &component) != nil {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString(component.Text)
+	for _, extra := range component.Extra {
+		builder.WriteString(flattenDescription(extra))
+	}
+
+	return builder.String()
+}
+
+func writePacket(writer io.Writer, id int32, data []byte) error {
+	var body bytes.Buffer
+	writeVarInt(&body, id)
+	body.Write(data)
+
+	var packet bytes.Buffer
+	writeVarInt(&packet, int32(body.Len()))
+	packet.Write(body.Bytes())
+
+	_, err := writer.Write(packet.Bytes())
+	return err
+}
+
+func readPacket(reader io.ByteReader) (int32, []byte, error) {
+	length, err := readVarInt(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if length <= 0 || length > maxPacketSize {
+		return 0, nil, fmt.Errorf("invalid packet length %v", length)
+	}
+
+	data := make([]byte, length)
+	for i := range data {
+		data[i], err = reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload := bytes.NewReader(data)
+	id, err := readVarInt(payload)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return id, data[len(data)-payload.Len():], nil
+}
+
+func writeVarInt(buffer *bytes.Buffer, value int32) {
+	unsigned := uint32(value)
+	for {
+		if unsigned&^0x7F == 0 {
+			buffer.WriteByte(byte(unsigned))
+			return
+		}
+
+		buffer.WriteByte(byte(unsigned&0x7F | 0x80))
+		unsigned >>= 7
+	}
+}
+
+func readVarInt(reader io.ByteReader) (int32, error) {
+	var value uint32
+
+	for i := 0; i < 5; i++ {
+		current, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		value |= uint32(current&0x7F) << (7 * i)
+		if current&0x80 == 0 {
+			return int32(value), nil
+		}
+	}
+
+	return 0, fmt.Errorf("var int is too big")
+}
+
+func writeString(buffer *bytes.Buffer, value string) {
+	writeVarInt(buffer, int32(len(value)))
+	buffer.WriteString(value)
+}
+
+func readString(reader *bytes.Reader) (string, error) {
+	length, err := readVarInt(reader)
+	if err != nil {
+		return "", err
+	}
+
+	if length < 0 || int(length) > reader.Len() {
+		return "", fmt.Errorf("invalid string length %v", length)
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(reader, data)
+
+	return string(data), err
+}
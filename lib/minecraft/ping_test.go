@@ -0,0 +1,157 @@
+package minecraft
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// The examples of https://wiki.vg/Protocol#VarInt_and_VarLong
+func TestVarInt(t *testing.T) {
+	for _, test := range []struct {
+		value   int32
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{1, []byte{0x01}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{255, []byte{0xff, 0x01}},
+		{25565, []byte{0xdd, 0xc7, 0x01}},
+		{2097151, []byte{0xff, 0xff, 0x7f}},
+		{2147483647, []byte{0xff, 0xff, 0xff, 0xff, 0x07}},
+		{-1, []byte{0xff, 0xff, 0xff, 0xff, 0x0f}},
+		{-2147483648, []byte{0x80, 0x80, 0x80, 0x80, 0x08}},
+	} {
+		var buffer bytes.Buffer
+		writeVarInt(&buffer, test.value)
+		if !bytes.Equal(buffer.Bytes(), test.encoded) {
+			t.Errorf("%v is encoded as %x, expected %x", test.value, buffer.Bytes(), test.encoded)
+		}
+
+		value, err := readVarInt(bytes.NewReader(test.encoded))
+		if err != nil || value != test.value {
+			t.Errorf("%x is decoded as %v (%v), expected %v", test.encoded, value, err, test.value)
+		}
+	}
+
+	if _, err := readVarInt(bytes.NewReader([]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x01})); err == nil {
+		t.Errorf("decoding a var int with more than 5 bytes succeeded")
+	}
+	if _, err := readVarInt(bytes.NewReader([]byte{0x80, 0x80})); err == nil {
+		t.Errorf("decoding a truncated var int succeeded")
+	}
+}
+
+// Answers the status request with the packet
+func newTestServer(t *testing.T, response []byte) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			reader := bufio.NewReader(conn)
+			// The handshake and the status request
+			for i := 0; i < 2; i++ {
+				if _, _, err := readPacket(reader); err != nil {
+					t.Errorf("couldn't read the request: %v", err)
+				}
+			}
+
+			_, _ = conn.Write(response)
+			_ = conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func statusPacket(json string) []byte {
+	var payload bytes.Buffer
+	writeString(&payload, json)
+
+	var packet bytes.Buffer
+	_ = writePacket(&packet, packetStatus, payload.Bytes())
+
+	return packet.Bytes()
+}
+
+func ping(address string) (*Status, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return Ping(ctx, address)
+}
+
+func TestPing(t *testing.T) {
+	address := newTestServer(t, statusPacket(`{
+		"version": {"name": "1.16.4", "protocol": 754},
+		"players": {"max": 20, "online": 2, "sample": [{"name": "Alex", "id": "1"}, {"name": "Steve", "id": "2"}]},
+		"description": {"text": "A ", "extra": [{"text": "Minecraft"}, " Server"]}
+	}`))
+
+	status, err := ping(address)
+	if err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+
+	if status.Version != "1.16.4" || status.Protocol != 754 || status.Online != 2 || status.Max != 20 {
+		t.Errorf("got status %+v", status)
+	}
+	if status.Motd != "A Minecraft Server" {
+		t.Errorf("got motd %q, expected the flattened chat components", status.Motd)
+	}
+	if len(status.Players) != 2 || status.Players[0] != "Alex" || status.Players[1] != "Steve" {
+		t.Errorf("got players %v", status.Players)
+	}
+}
+
+func TestPingPlainDescription(t *testing.T) {
+	address := newTestServer(t, statusPacket(`{"version": {"name": "1.8.9", "protocol": 47},
+		"players": {"max": 10, "online": 0}, "description": "Old Server"}`))
+
+	status, err := ping(address)
+	if err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+
+	if status.Motd != "Old Server" || len(status.Players) != 0 {
+		t.Errorf("got status %+v", status)
+	}
+}
+
+func TestPingTruncated(t *testing.T) {
+	packet := statusPacket(`{"version": {"name": "1.16.4", "protocol": 754}, "players": {"max": 20, "online": 0}}`)
+
+	// Cut in the length, the id and the json
+	for _, length := range []int{0, 1, 2, 10, len(packet) - 1} {
+		address := newTestServer(t, packet[:length])
+
+		if _, err := ping(address); err == nil {
+			t.Errorf("the response truncated to %v of %v bytes was accepted", length, len(packet))
+		}
+	}
+
+	// The packet is complete, but the string is longer than the packet
+	var payload bytes.Buffer
+	writeVarInt(&payload, 100)
+	payload.WriteString("{}")
+
+	var invalid bytes.Buffer
+	_ = writePacket(&invalid, packetStatus, payload.Bytes())
+
+	if _, err := ping(newTestServer(t, invalid.Bytes())); err == nil {
+		t.Errorf("a string longer than its packet was accepted")
+	}
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"start-my-game/lib/config"
+	"time"
+)
+
+const discordType string = "discord"
+
+// Shown as the author of the messages
+const discordUsername = "StartMyGame"
+
+// Posts the notifications as messages to a Discord webhook
+type DiscordNotifier struct {
+	url    string
+	client *http.Client
+}
+
+type discordMessage struct {
+	Username string `json:"username"`
+	Content  string `json:"content"`
+}
+
+func (notifier *DiscordNotifier) Notify(notification Notification) error {
+	body, err := json.Marshal(discordMessage{
+		Username: discordUsername,
+		Content:  notification.Message,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't compose message: %v", err)
+	}
+
+	response, err := notifier.client.Post(notifier.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("couldn't reach discord: %v", err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("discord responded with %v", response.Status)
+	}
+
+	return nil
+}
+
+func newDiscordNotifier(cfg config.Notification) (*DiscordNotifier, error) {
+	if cfg.Url == "" {
+		return nil, fmt.Errorf("the discord notifier needs a webhook url")
+	}
+
+	return &DiscordNotifier{
+		url:    cfg.Url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"start-my-game/lib/config"
+	"testing"
+)
+
+func TestDiscordMessage(t *testing.T) {
+	var received discordMessage
+	status := http.StatusNoContent
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got content type %v", request.Header.Get("Content-Type"))
+		}
+
+		err := json.NewDecoder(request.Body).Decode(&received)
+		if err != nil {
+			t.Errorf("couldn't decode the message: %v", err)
+		}
+
+		writer.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	notifier, err := newDiscordNotifier(config.Notification{Type: discordType, Url: server.URL})
+	if err != nil {
+		t.Fatalf("couldn't create the notifier: %v", err)
+	}
+
+	err = notifier.Notify(testNotification)
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	if received.Username != discordUsername || received.Content != testNotification.Message {
+		t.Errorf("got message %+v", received)
+	}
+
+	status = http.StatusTooManyRequests
+	err = notifier.Notify(testNotification)
+	if err == nil {
+		t.Errorf("expected an error for status %v", status)
+	}
+}
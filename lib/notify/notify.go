@@ -0,0 +1,261 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"start-my-game/lib/cloud"
+	"start-my-game/lib/config"
+	"start-my-game/lib/game"
+	"start-my-game/lib/manager"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindStart        Kind = "start"
+	KindOnline       Kind = "online"
+	KindStartupError Kind = "startup_error"
+	KindIdleWarning  Kind = "idle_warning"
+	// Taking the snapshot or destroying the server failed, the server still exists
+	KindShutdownError Kind = "shutdown_error"
+	KindDestroyed     Kind = "destroyed"
+	// All other transitions of the server lifecycle
+	KindTransition Kind = "transition"
+)
+
+var kinds = []Kind{KindStart, KindOnline, KindStartupError, KindIdleWarning, KindShutdownError, KindDestroyed,
+	KindTransition}
+
+// Sent if no events are configured for a notifier
+var defaultKinds = map[string][]Kind{
+	discordType: {KindStart, KindOnline, KindStartupError, KindIdleWarning, KindShutdownError, KindDestroyed},
+	webhookType: kinds,
+}
+
+// Notifications waiting for a slow notifier, newer ones are dropped if the queue is full
+const queueSize = 16
+
+type Notification struct {
+	Kind Kind
+	Time time.Time
+	// The name of the cloud server
+	Server   string
+	Provider string
+	// A human readable description of the event
+	Message string
+	// Only set for KindStart
+	Requester string
+	// Empty if there's no server
+	Ip string
+	// Only set for KindOnline
+	ConnectLink string
+	// Players at the last check of the game server
+	Players int
+	// The lifecycle transition, all empty for KindStart
+	From   manager.State
+	To     manager.State
+	Reason string
+}
+
+// Sends notifications to a single destination
+type Notifier interface {
+	Notify(notification Notification) error
+}
+
+// Turns the events of the manager into notifications for all configured notifiers
+type Dispatcher struct {
+	game       game.Adapter
+	provider   string
+	serverName string
+	targets    []*target
+}
+
+type target struct {
+	name     string
+	notifier Notifier
+	kinds    map[Kind]bool
+	queue    chan Notification
+}
+
+// Runs until the manager stops, doesn't do anything if there are no notifiers
+func (dispatcher *Dispatcher) Run(manager *manager.Manager) {
+	if len(dispatcher.targets) == 0 {
+		return
+	}
+
+	for _, target := range dispatcher.targets {
+		go target.run()
+	}
+
+	events, _, unsubscribe := manager.Subscribe(manager.LastEventId())
+	defer unsubscribe()
+
+	for event := range events {
+		notification, ok := dispatcher.notificationFor(event)
+		if !ok {
+			continue
+		}
+
+		for _, target := range dispatcher.targets {
+			target.enqueue(notification)
+		}
+	}
+}
+
+// Returns false if there's no notification for the event
+func (dispatcher *Dispatcher) notificationFor(event manager.Event) (Notification, bool) {
+	notification := Notification{
+		Time:     event.Time,
+		Server:   dispatcher.serverName,
+		Provider: dispatcher.provider,
+		Ip:       event.Ip,
+		Players:  event.Players,
+	}
+
+	if event.Type == manager.EventStartRequested {
+		notification.Kind = KindStart
+		notification.Requester = event.Requester
+		notification.Message = fmt.Sprintf("%v is starting, requested by %v", notification.Server, event.Requester)
+		return notification, true
+	}
+
+	if event.Type != manager.EventTransition {
+		return notification, false
+	}
+
+	transition := event.Transition
+	notification.From = transition.From
+	notification.To = transition.To
+	notification.Reason = transition.Reason
+
+	switch {
+	case transition.To == manager.StateRunning && transition.From == manager.StateWaitingForGame:
+		notification.Kind = KindOnline
+		if notification.Ip != "" {
+			notification.ConnectLink = dispatcher.game.ConnectLink(notification.Ip)
+		}
+		notification.Message = fmt.Sprintf("%v is online: %v", notification.Server, notification.ConnectLink)
+	case transition.To == manager.StateError && isStartup(transition.From):
+		notification.Kind = KindStartupError
+		notification.Message = fmt.Sprintf("%v couldn't be started: %v", notification.Server, transition.Reason)
+	case transition.To == manager.StateError &&
+		(transition.From == manager.StateStopping || transition.From == manager.StateDestroying):
+		notification.Kind = KindShutdownError
+		notification.Message = fmt.Sprintf("%v couldn't be shut down, it's still running: %v",
+			notification.Server, transition.Reason)
+	case transition.To == manager.StateIdleWarning:
+		notification.Kind = KindIdleWarning
+		notification.Message = fmt.Sprintf("%v: %v", notification.Server, transition.Reason)
+	case transition.To == manager.StateOff && transition.From == manager.StateDestroying:
+		notification.Kind = KindDestroyed
+		notification.Message = fmt.Sprintf("%v was shut down", notification.Server)
+	default:
+		notification.Kind = KindTransition
+		notification.Message = fmt.Sprintf("%v changed from %v to %v: %v", notification.Server,
+			transition.From, transition.To, transition.Reason)
+	}
+
+	return notification, true
+}
+
+// The states from which a failure is a failed start
+func isStartup(state manager.State) bool {
+	switch state {
+	case manager.StateOff, manager.StateCreating, manager.StateBooting, manager.StateWaitingForGame:
+		return true
+	}
+
+	return false
+}
+
+func (target *target) enqueue(notification Notification) {
+	if !target.kinds[notification.Kind] {
+		return
+	}
+
+	select {
+	case target.queue <- notification:
+	default:
+		log.Printf("Dropped the %v notification for %v, too many are waiting\n", notification.Kind, target.name)
+	}
+}
+
+func (target *target) run() {
+	for notification := range target.queue {
+		err := target.notifier.Notify(notification)
+		if err != nil {
+			log.Printf("Couldn't send the %v notification to %v: %v\n", notification.Kind, target.name, err)
+		}
+	}
+}
+
+func NewDispatcher(cfg *config.Config, acloud cloud.Cloud, adapter game.Adapter) (*Dispatcher, error) {
+	dispatcher := &Dispatcher{
+		game:       adapter,
+		provider:   acloud.GetProvider(),
+		serverName: cfg.Cloud.ServerName,
+	}
+
+	for i, notificationCfg := range cfg.Notifications {
+		notifier, err := newNotifier(notificationCfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notification %v: %v", i+1, err)
+		}
+
+		selected, err := parseKinds(notificationCfg.Events, defaultKinds[strings.ToLower(notificationCfg.Type)])
+		if err != nil {
+			return nil, fmt.Errorf("invalid notification %v: %v", i+1, err)
+		}
+
+		dispatcher.targets = append(dispatcher.targets, &target{
+			name:     fmt.Sprintf("%v notifier %v", notificationCfg.Type, i+1),
+			notifier: notifier,
+			kinds:    selected,
+			queue:    make(chan Notification, queueSize),
+		})
+	}
+
+	return dispatcher, nil
+}
+
+func newNotifier(cfg config.Notification) (Notifier, error) {
+	notifierType := strings.ToLower(cfg.Type)
+
+	switch notifierType {
+	case discordType:
+		return newDiscordNotifier(cfg)
+	case webhookType:
+		return newWebhookNotifier(cfg)
+	}
+
+	return nil, fmt.Errorf("notifier with type '%v' not found", notifierType)
+}
+
+func parseKinds(events []string, defaults []Kind) (map[Kind]bool, error) {
+	selected := make(map[Kind]bool)
+
+	if len(events) == 0 {
+		for _, kind := range defaults {
+			selected[kind] = true
+		}
+		return selected, nil
+	}
+
+	for _, event := range events {
+		found := false
+		for _, kind := range kinds {
+			if Kind(strings.ToLower(event)) == kind {
+				selected[kind] = true
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown event '%v'", event)
+		}
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"start-my-game/lib/config"
+	"start-my-game/lib/game"
+	"start-my-game/lib/manager"
+	"testing"
+	"time"
+)
+
+var testNotification = Notification{
+	Kind:    KindTransition,
+	Time:    time.Date(2020, 1, 2, 15, 4, 0, 0, time.UTC),
+	Server:  "gmod",
+	Message: "gmod changed from running to stopping: stopping the server",
+	From:    manager.StateRunning,
+	To:      manager.StateStopping,
+	Reason:  "stopping the server",
+}
+
+func TestEnqueueDropsWhenFull(t *testing.T) {
+	// The queue isn't consumed, like the one of a slow notifier
+	target := &target{
+		name:  "test notifier",
+		kinds: map[Kind]bool{KindTransition: true},
+		queue: make(chan Notification, queueSize),
+	}
+
+	for i := 0; i < queueSize+5; i++ {
+		target.enqueue(testNotification)
+	}
+
+	if len(target.queue) != queueSize {
+		t.Errorf("got %v queued notifications, expected %v", len(target.queue), queueSize)
+	}
+
+	// Kinds which aren't selected aren't queued at all
+	started := testNotification
+	started.Kind = KindStart
+	target.enqueue(started)
+	for len(target.queue) > 0 {
+		if notification := <-target.queue; notification.Kind != KindTransition {
+			t.Errorf("got a %v notification, which isn't selected", notification.Kind)
+		}
+	}
+}
+
+func TestParseKinds(t *testing.T) {
+	selected, err := parseKinds([]string{"Start", "online"}, kinds)
+	if err != nil {
+		t.Fatalf("parseKinds failed: %v", err)
+	}
+
+	if len(selected) != 2 || !selected[KindStart] || !selected[KindOnline] {
+		t.Errorf("got %v", selected)
+	}
+
+	selected, _ = parseKinds(nil, defaultKinds[discordType])
+	if selected[KindTransition] {
+		t.Errorf("discord notifiers shouldn't get all transitions by default")
+	}
+
+	_, err = parseKinds([]string{"unknown"}, kinds)
+	if err == nil {
+		t.Errorf("expected an error for an unknown event")
+	}
+}
+
+func TestNotificationFor(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Game.Type = "gmod"
+	adapter, _ := game.NewAdapter(cfg)
+	dispatcher := &Dispatcher{game: adapter, provider: "fake", serverName: "gmod"}
+
+	for _, test := range []struct {
+		from manager.State
+		to   manager.State
+		kind Kind
+	}{
+		{manager.StateWaitingForGame, manager.StateRunning, KindOnline},
+		{manager.StateOff, manager.StateRunning, KindTransition},
+		{manager.StateCreating, manager.StateError, KindStartupError},
+		{manager.StateBooting, manager.StateError, KindStartupError},
+		{manager.StateWaitingForGame, manager.StateError, KindStartupError},
+		{manager.StateStopping, manager.StateError, KindShutdownError},
+		{manager.StateDestroying, manager.StateError, KindShutdownError},
+		{manager.StateRunning, manager.StateIdleWarning, KindIdleWarning},
+		{manager.StateDestroying, manager.StateOff, KindDestroyed},
+		{manager.StateRunning, manager.StateOff, KindTransition},
+	} {
+		event := manager.Event{
+			Type:       manager.EventTransition,
+			Transition: &manager.Transition{From: test.from, To: test.to, Reason: "test"},
+			Ip:         "192.0.2.1",
+			Players:    3,
+		}
+
+		notification, ok := dispatcher.notificationFor(event)
+		if !ok || notification.Kind != test.kind {
+			t.Errorf("the transition from %v to %v resulted in %v, expected %v", test.from, test.to,
+				notification.Kind, test.kind)
+		}
+
+		// The fields are the ones of the time of the event, not of the delivery
+		if notification.Ip != "192.0.2.1" || notification.Players != 3 {
+			t.Errorf("got the ip %q and %v players", notification.Ip, notification.Players)
+		}
+	}
+
+	notification, _ := dispatcher.notificationFor(manager.Event{
+		Type:       manager.EventTransition,
+		Transition: &manager.Transition{From: manager.StateWaitingForGame, To: manager.StateRunning},
+		Ip:         "192.0.2.1",
+	})
+	if notification.ConnectLink != "steam://connect/192.0.2.1" {
+		t.Errorf("got the connect link %q", notification.ConnectLink)
+	}
+
+	if _, ok := dispatcher.notificationFor(manager.Event{Type: manager.EventStatus}); ok {
+		t.Errorf("got a notification for a status event")
+	}
+}
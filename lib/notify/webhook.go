@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"start-my-game/lib/config"
+	"time"
+)
+
+const webhookType string = "webhook"
+
+// Contains the hex encoded HMAC-SHA256 of the body prefixed with "sha256="
+const signatureHeader = "X-SMG-Signature"
+
+const eventHeader = "X-SMG-Event"
+
+const (
+	webhookAttempts = 4
+	// Doubled after every failed attempt
+	webhookBackoff = 2 * time.Second
+)
+
+// Posts every notification as signed JSON to an URL
+type WebhookNotifier struct {
+	url     string
+	secret  []byte
+	client  *http.Client
+	backoff time.Duration
+}
+
+type WebhookPayload struct {
+	Type      Kind      `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Server    string    `json:"server"`
+	Provider  string    `json:"provider"`
+	Ip        string    `json:"ip"`
+	Players   int       `json:"players"`
+	Message   string    `json:"message"`
+	// The following fields are empty if they don't belong to the type
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Reason      string `json:"reason"`
+	Requester   string `json:"requester"`
+	ConnectLink string `json:"connect_link"`
+}
+
+// Errors returned by the receiver, which won't change by trying again
+type permanentError struct {
+	status string
+}
+
+func (err *permanentError) Error() string {
+	return fmt.Sprintf("the webhook responded with %v", err.status)
+}
+
+// Retries failed deliveries with an increasing delay, the following notifications wait in the queue
+func (notifier *WebhookNotifier) Notify(notification Notification) error {
+	body, err := json.Marshal(WebhookPayload{
+		Type:        notification.Kind,
+		Timestamp:   notification.Time,
+		Server:      notification.Server,
+		Provider:    notification.Provider,
+		Ip:          notification.Ip,
+		Players:     notification.Players,
+		Message:     notification.Message,
+		From:        string(notification.From),
+		To:          string(notification.To),
+		Reason:      notification.Reason,
+		Requester:   notification.Requester,
+		ConnectLink: notification.ConnectLink,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't compose payload: %v", err)
+	}
+
+	backoff := notifier.backoff
+	for attempt := 1; ; attempt++ {
+		err = notifier.deliver(notification.Kind, body)
+		if err == nil {
+			return nil
+		}
+
+		if _, ok := err.(*permanentError); ok || attempt == webhookAttempts {
+			return fmt.Errorf("giving up after %v attempts: %v", attempt, err)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (notifier *WebhookNotifier) deliver(kind Kind, body []byte) error {
+	request, err := http.NewRequest("POST", notifier.url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{status: err.Error()}
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(eventHeader, string(kind))
+	request.Header.Set(signatureHeader, "sha256="+notifier.sign(body))
+
+	response, err := notifier.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("couldn't reach the webhook: %v", err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return nil
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return fmt.Errorf("the webhook responded with %v", response.Status)
+	default:
+		return &permanentError{status: response.Status}
+	}
+}
+
+func (notifier *WebhookNotifier) sign(body []byte) string {
+	mac := hmac.New(sha256.New, notifier.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookNotifier(cfg config.Notification) (*WebhookNotifier, error) {
+	if cfg.Url == "" {
+		return nil, fmt.Errorf("the webhook notifier needs an url")
+	}
+
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("the webhook notifier needs a secret to sign the payloads")
+	}
+
+	return &WebhookNotifier{
+		url:     cfg.Url,
+		secret:  []byte(cfg.Secret),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		backoff: webhookBackoff,
+	}, nil
+}
@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"start-my-game/lib/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+type webhookRequest struct {
+	body      []byte
+	signature string
+	event     string
+}
+
+// Responds with the statuses in order, the last one is repeated
+func newWebhookServer(t *testing.T, statuses ...int) (*httptest.Server, func() []webhookRequest) {
+	var mutex sync.Mutex
+	var requests []webhookRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+
+		mutex.Lock()
+		requests = append(requests, webhookRequest{
+			body:      body,
+			signature: request.Header.Get(signatureHeader),
+			event:     request.Header.Get(eventHeader),
+		})
+		status := statuses[len(statuses)-1]
+		if len(requests) <= len(statuses) {
+			status = statuses[len(requests)-1]
+		}
+		mutex.Unlock()
+
+		writer.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []webhookRequest {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]webhookRequest(nil), requests...)
+	}
+}
+
+func newTestWebhookNotifier(t *testing.T, url string) *WebhookNotifier {
+	notifier, err := newWebhookNotifier(config.Notification{Type: webhookType, Url: url, Secret: "secret"})
+	if err != nil {
+		t.Fatalf("couldn't create the notifier: %v", err)
+	}
+	notifier.backoff = time.Millisecond
+
+	return notifier
+}
+
+func TestWebhookSignature(t *testing.T) {
+	server, requests := newWebhookServer(t, http.StatusNoContent)
+
+	err := newTestWebhookNotifier(t, server.URL).Notify(testNotification)
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	received := requests()
+	if len(received) != 1 {
+		t.Fatalf("expected 1 request, got %v", len(received))
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(received[0].body)
+	if expected := "sha256=" + hex.EncodeToString(mac.Sum(nil)); received[0].signature != expected {
+		t.Errorf("got signature %v, expected %v", received[0].signature, expected)
+	}
+
+	if received[0].event != string(KindTransition) {
+		t.Errorf("got event %v, expected %v", received[0].event, KindTransition)
+	}
+
+	var payload WebhookPayload
+	err = json.Unmarshal(received[0].body, &payload)
+	if err != nil {
+		t.Fatalf("couldn't decode the payload: %v", err)
+	}
+
+	if payload.Type != KindTransition || payload.From != "running" || payload.To != "stopping" ||
+		!payload.Timestamp.Equal(testNotification.Time) {
+		t.Errorf("got payload %+v", payload)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	server, requests := newWebhookServer(t, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK)
+
+	err := newTestWebhookNotifier(t, server.URL).Notify(testNotification)
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	received := requests()
+	if len(received) != 3 {
+		t.Fatalf("expected 3 attempts, got %v", len(received))
+	}
+
+	// Every attempt is signed the same way
+	for _, request := range received[1:] {
+		if request.signature != received[0].signature {
+			t.Errorf("got signature %v on retry, expected %v", request.signature, received[0].signature)
+		}
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	server, requests := newWebhookServer(t, http.StatusInternalServerError)
+
+	notifier := newTestWebhookNotifier(t, server.URL)
+	notifier.backoff = 10 * time.Millisecond
+
+	start := time.Now()
+	err := notifier.Notify(testNotification)
+	if err == nil {
+		t.Fatalf("expected Notify to fail")
+	}
+
+	if received := requests(); len(received) != webhookAttempts {
+		t.Errorf("expected %v attempts, got %v", webhookAttempts, len(received))
+	}
+
+	// The delays are 10, 20 and 40 milliseconds
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("the attempts took %v, expected an increasing backoff", elapsed)
+	}
+}
+
+func TestWebhookPermanentError(t *testing.T) {
+	server, requests := newWebhookServer(t, http.StatusBadRequest)
+
+	err := newTestWebhookNotifier(t, server.URL).Notify(testNotification)
+	if err == nil {
+		t.Fatalf("expected Notify to fail")
+	}
+
+	if received := requests(); len(received) != 1 {
+		t.Errorf("expected no retry of a client error, got %v attempts", len(received))
+	}
+}
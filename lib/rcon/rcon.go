@@ -0,0 +1,301 @@
+package rcon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// https://developer.valvesoftware.com/wiki/Source_RCON_Protocol
+const (
+	typeAuth          int32 = 3
+	typeAuthResponse  int32 = 2
+	typeExecCommand   int32 = 2
+	typeResponseValue int32 = 0
+
+	// Id, type and the two null bytes
+	minPacketSize int32 = 10
+	// Source servers split responses into packets with a body of at most 4096 bytes
+	maxPacketSize int32 = 4096 + minPacketSize
+)
+
+var ErrAuthentication = errors.New("rcon authentication failed")
+
+// A client keeps a single authenticated connection and reconnects when it breaks.
+// It's safe to use the client from multiple goroutines, commands are executed one after another.
+type Client struct {
+	address  string
+	password string
+	// Enables the empty response value trick to read responses consisting of multiple packets.
+	// Servers which don't mirror an empty response value packet (e.g. Minecraft) need it disabled.
+	multiPacket bool
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	lastId int32
+}
+
+type packet struct {
+	id   int32
+	kind int32
+	body string
+}
+
+// Connects and authenticates if there's no open connection
+func (client *Client) Connect(ctx context.Context) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	return client.connect(ctx)
+}
+
+// Executes a command and returns the complete response.
+// A reused connection which turns out to be broken is replaced once by a new one.
+func (client *Client) Execute(ctx context.Context, command string) (string, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	reused := client.conn != nil
+
+	response, err := client.execute(ctx, command)
+	if err != nil && reused && ctx.Err() == nil && !errors.Is(err, ErrAuthentication) {
+		response, err = client.execute(ctx, command)
+	}
+
+	return response, err
+}
+
+func (client *Client) Close() error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	return client.close()
+}
+
+func (client *Client) execute(ctx context.Context, command string) (string, error) {
+	err := client.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	stop := client.watch(ctx)
+	defer stop()
+
+	response, err := client.exchange(command)
+	if err != nil {
+		_ = client.close()
+		return "", fmt.Errorf("couldn't execute '%v' on %v: %w", command, client.address, contextError(ctx, err))
+	}
+
+	return response, nil
+}
+
+func (client *Client) exchange(command string) (string, error) {
+	commandId := client.nextId()
+	err := client.write(commandId, typeExecCommand, command)
+	if err != nil {
+		return "", err
+	}
+
+	sentinelId := int32(0)
+	if client.multiPacket {
+		// The server answers this packet after it has sent all packets of the command
+		sentinelId = client.nextId()
+		err = client.write(sentinelId, typeResponseValue, "")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var response bytes.Buffer
+
+	for {
+		received, err := client.read()
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case received.id == commandId && received.kind == typeResponseValue:
+			response.WriteString(received.body)
+			if !client.multiPacket {
+				return response.String(), nil
+			}
+		case client.multiPacket && received.id == sentinelId:
+			return response.String(), nil
+		default:
+			// Leftovers of a previous command, e.g. the second answer to an empty response value packet
+		}
+	}
+}
+
+// The mutex must be locked while calling this method
+func (client *Client) connect(ctx context.Context) error {
+	if client.conn != nil {
+		return nil
+	}
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", client.address)
+	if err != nil {
+		return fmt.Errorf("couldn't connect via rcon to '%v': %v", client.address, err)
+	}
+
+	client.conn = conn
+	client.reader = bufio.NewReader(conn)
+
+	stop := client.watch(ctx)
+	defer stop()
+
+	err = client.authenticate()
+	if err != nil {
+		_ = client.close()
+		return fmt.Errorf("couldn't authenticate via rcon at '%v': %w", client.address, contextError(ctx, err))
+	}
+
+	return nil
+}
+
+func (client *Client) authenticate() error {
+	authId := client.nextId()
+	err := client.write(authId, typeAuth, client.password)
+	if err != nil {
+		return err
+	}
+
+	for {
+		received, err := client.read()
+		if err != nil {
+			return err
+		}
+
+		// Source servers send an empty response value packet before the actual answer
+		if received.kind != typeAuthResponse {
+			continue
+		}
+
+		if received.id == -1 {
+			return ErrAuthentication
+		}
+
+		if received.id == authId {
+			return nil
+		}
+	}
+}
+
+// The mutex must be locked while calling this method
+func (client *Client) close() error {
+	if client.conn == nil {
+		return nil
+	}
+
+	err := client.conn.Close()
+	client.conn = nil
+	client.reader = nil
+
+	return err
+}
+
+// Applies the deadline of the context to the connection and interrupts blocking calls if it's canceled.
+// The returned function must be called after the connection isn't used anymore with the context.
+func (client *Client) watch(ctx context.Context) func() {
+	conn := client.conn
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+
+		select {
+		case <-ctx.Done():
+			// The connection could already be used with the next context
+			select {
+			case <-done:
+			default:
+				_ = conn.SetDeadline(time.Unix(1, 0))
+			}
+		case <-done:
+		}
+	}()
+
+	// Waiting for the goroutine, so it can't interrupt the next use of the connection
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+func (client *Client) nextId() int32 {
+	client.lastId++
+	if client.lastId <= 0 {
+		client.lastId = 1
+	}
+
+	return client.lastId
+}
+
+func (client *Client) write(id int32, kind int32, body string) error {
+	var buffer bytes.Buffer
+
+	size := int32(len(body)) + minPacketSize
+	_ = binary.Write(&buffer, binary.LittleEndian, size)
+	_ = binary.Write(&buffer, binary.LittleEndian, id)
+	_ = binary.Write(&buffer, binary.LittleEndian, kind)
+	buffer.WriteString(body)
+	buffer.Write([]byte{0, 0})
+
+	_, err := client.conn.Write(buffer.Bytes())
+	return err
+}
+
+func (client *Client) read() (*packet, error) {
+	var size int32
+	err := binary.Read(client.reader, binary.LittleEndian, &size)
+	if err != nil {
+		return nil, err
+	}
+
+	if size < minPacketSize || size > maxPacketSize {
+		return nil, fmt.Errorf("invalid packet size %v", size)
+	}
+
+	data := make([]byte, size)
+	_, err = io.ReadFull(client.reader, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &packet{
+		id:   int32(binary.LittleEndian.Uint32(data[0:4])),
+		kind: int32(binary.LittleEndian.Uint32(data[4:8])),
+		body: string(bytes.TrimRight(data[8:], "\x00")),
+	}, nil
+}
+
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+func NewClient(address string, password string, multiPacket bool) *Client {
+	return &Client{
+		address:     address,
+		password:    password,
+		multiPacket: multiPacket,
+	}
+}
@@ -0,0 +1,309 @@
+package rcon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testPassword = "secret"
+
+// A stand-in for a Source server. The command 'echo <text>' answers with the text, 'long' with
+// three full packets, 'drop' closes the connection and 'hang' never answers.
+type testServer struct {
+	listener net.Listener
+
+	mutex       sync.Mutex
+	conns       []net.Conn
+	connections int
+}
+
+func (server *testServer) serve() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		server.mutex.Lock()
+		server.conns = append(server.conns, conn)
+		server.connections++
+		server.mutex.Unlock()
+
+		go server.handle(conn)
+	}
+}
+
+func (server *testServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		var size int32
+		if binary.Read(reader, binary.LittleEndian, &size) != nil {
+			return
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return
+		}
+
+		id := int32(binary.LittleEndian.Uint32(data[0:4]))
+		kind := int32(binary.LittleEndian.Uint32(data[4:8]))
+		body := string(bytes.TrimRight(data[8:], "\x00"))
+
+		switch {
+		case kind == typeAuth:
+			// Source servers send an empty response value before the result of the authentication
+			writeTestPacket(conn, id, typeResponseValue, "")
+			if body != testPassword {
+				id = -1
+			}
+			writeTestPacket(conn, id, typeAuthResponse, "")
+		case kind == typeResponseValue:
+			// The empty response value is mirrored and followed by a second packet
+			writeTestPacket(conn, id, typeResponseValue, "")
+			writeTestPacket(conn, id, typeResponseValue, "\x00\x01\x00\x00")
+		case strings.HasPrefix(body, "echo "):
+			writeTestPacket(conn, id, typeResponseValue, strings.TrimPrefix(body, "echo "))
+		case body == "long":
+			for _, part := range []string{"a", "b", "c"} {
+				writeTestPacket(conn, id, typeResponseValue, strings.Repeat(part, 4096))
+			}
+		case body == "drop":
+			return
+		case body == "hang":
+			// Nothing is answered anymore, not even the empty response value
+			_, _ = io.Copy(ioutil.Discard, reader)
+			return
+		}
+	}
+}
+
+// Closes all open connections, like a restarting game server
+func (server *testServer) drop() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for _, conn := range server.conns {
+		_ = conn.Close()
+	}
+	server.conns = nil
+}
+
+func (server *testServer) connectionCount() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return server.connections
+}
+
+func writeTestPacket(conn net.Conn, id int32, kind int32, body string) {
+	var buffer bytes.Buffer
+
+	_ = binary.Write(&buffer, binary.LittleEndian, int32(len(body))+minPacketSize)
+	_ = binary.Write(&buffer, binary.LittleEndian, id)
+	_ = binary.Write(&buffer, binary.LittleEndian, kind)
+	buffer.WriteString(body)
+	buffer.Write([]byte{0, 0})
+
+	_, _ = conn.Write(buffer.Bytes())
+}
+
+// Starts a server, which is closed after the test
+func newTestServer(t *testing.T) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen: %v", err)
+	}
+
+	server := &testServer{listener: listener}
+	go server.serve()
+
+	t.Cleanup(func() {
+		_ = listener.Close()
+		server.drop()
+	})
+
+	return server
+}
+
+func newTestClient(t *testing.T, server *testServer, password string) *Client {
+	client := NewClient(server.listener.Addr().String(), password, true)
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func execute(client *Client, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return client.Execute(ctx, command)
+}
+
+func TestMultiPacketResponse(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, testPassword)
+
+	response, err := execute(client, "long")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	expected := strings.Repeat("a", 4096) + strings.Repeat("b", 4096) + strings.Repeat("c", 4096)
+	if response != expected {
+		t.Errorf("got a response of %v bytes, expected the %v bytes of all packets", len(response), len(expected))
+	}
+
+	// The second answer to the empty response value of the last command is skipped
+	response, err = execute(client, "echo status")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if response != "status" {
+		t.Errorf("got response %q, expected %q", response, "status")
+	}
+
+	if connections := server.connectionCount(); connections != 1 {
+		t.Errorf("the connection wasn't reused, got %v connections", connections)
+	}
+}
+
+func TestAuthenticationFailure(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, "wrong")
+
+	_, err := execute(client, "echo status")
+	if !errors.Is(err, ErrAuthentication) {
+		t.Fatalf("expected an authentication error, got %v", err)
+	}
+
+	if connections := server.connectionCount(); connections != 1 {
+		t.Errorf("a failed authentication shouldn't be retried, got %v connections", connections)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, testPassword)
+
+	if _, err := execute(client, "echo first"); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	server.drop()
+
+	response, err := execute(client, "echo second")
+	if err != nil {
+		t.Fatalf("Execute failed after the connection was dropped: %v", err)
+	}
+	if response != "second" {
+		t.Errorf("got response %q, expected %q", response, "second")
+	}
+
+	if connections := server.connectionCount(); connections != 2 {
+		t.Errorf("expected a second connection, got %v", connections)
+	}
+
+	// A connection dropped during the command isn't retried forever
+	if _, err := execute(client, "drop"); err == nil {
+		t.Errorf("expected an error for a command which drops the connection")
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, testPassword)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	started := time.Now()
+	_, err := client.Execute(ctx, "hang")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation as error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("the canceled command returned after %v", elapsed)
+	}
+
+	// The interrupted connection is replaced
+	if _, err := execute(client, "echo status"); err != nil {
+		t.Fatalf("Execute failed after the cancellation: %v", err)
+	}
+}
+
+func TestCancelAfterReturn(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, testPassword)
+
+	// Canceling the context of a finished command mustn't interrupt the next one
+	for i := 0; i < 200; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := client.Execute(ctx, "echo status")
+		cancel()
+
+		if err != nil {
+			t.Fatalf("Execute %v failed: %v", i, err)
+		}
+	}
+
+	if connections := server.connectionCount(); connections != 1 {
+		t.Errorf("the connection wasn't reused, got %v connections", connections)
+	}
+}
+
+// Fails the test if the deadline is changed after the connection was released
+type releasedConn struct {
+	net.Conn
+	t        *testing.T
+	released int32
+	mutex    sync.Mutex
+}
+
+func (conn *releasedConn) SetDeadline(deadline time.Time) error {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	if conn.released > 0 {
+		conn.t.Errorf("the deadline was changed to %v after the connection was released", deadline)
+	}
+	return nil
+}
+
+func (conn *releasedConn) release() {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	conn.released++
+}
+
+func TestWatchStop(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		local, remote := net.Pipe()
+		conn := &releasedConn{Conn: local, t: t}
+		client := &Client{conn: conn}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stop := client.watch(ctx)
+		cancel()
+		stop()
+		conn.release()
+
+		// A goroutine which is still running would change the deadline now
+		time.Sleep(time.Millisecond)
+		_ = local.Close()
+		_ = remote.Close()
+	}
+}
@@ -0,0 +1,196 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A cron expression with the fields minute, hour, day of month, month and day of week
+type Cron struct {
+	minutes uint64
+	hours   uint64
+	days    uint64
+	months  uint64
+	weekday uint64
+	// Like cron, a day matches if either the day of month or the day of week matches,
+	// unless one of them covers its full range like "*"
+	anyDay     bool
+	anyWeekday bool
+}
+
+type cronField struct {
+	min   int
+	max   int
+	names []string
+}
+
+var (
+	minuteField  = cronField{min: 0, max: 59}
+	hourField    = cronField{min: 0, max: 23}
+	dayField     = cronField{min: 1, max: 31}
+	monthField   = cronField{min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	weekdayField = cronField{min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// Parses expressions like "45 19 * * fri" or "*/15 0-6 * * *"
+func ParseCron(expression string) (*Cron, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression '%v' needs 5 fields, got %v", expression, len(fields))
+	}
+
+	cron := &Cron{}
+
+	var err error
+	parsed := []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&cron.minutes, minuteField},
+		{&cron.hours, hourField},
+		{&cron.days, dayField},
+		{&cron.months, monthField},
+		{&cron.weekday, weekdayField},
+	}
+
+	for i, part := range parsed {
+		*part.bits, err = part.field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression '%v': %v", expression, err)
+		}
+	}
+
+	// Sunday is 0 and 7
+	if cron.weekday&(1<<7) != 0 {
+		cron.weekday |= 1
+	}
+
+	cron.anyDay = cron.days == dayField.all()
+	cron.anyWeekday = cron.weekday|1<<7 == weekdayField.all()
+
+	return cron, nil
+}
+
+// Whether the minute of the time matches the expression
+func (cron *Cron) Matches(t time.Time) bool {
+	return cron.minutes&(1<<uint(t.Minute())) != 0 &&
+		cron.hours&(1<<uint(t.Hour())) != 0 &&
+		cron.months&(1<<uint(t.Month())) != 0 &&
+		cron.matchesDay(t)
+}
+
+func (cron *Cron) matchesDay(t time.Time) bool {
+	day := cron.days&(1<<uint(t.Day())) != 0
+	weekday := cron.weekday&(1<<uint(t.Weekday())) != 0
+
+	if cron.anyDay || cron.anyWeekday {
+		return day && weekday
+	}
+
+	return day || weekday
+}
+
+// The first matching minute after the time in its location, the zero time if there's none within five years.
+// Minutes which are skipped by the start of the daylight saving time never match.
+func (cron *Cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	location := t.Location()
+
+	for t.Before(limit) {
+		if cron.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if !cron.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if cron.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, location)
+			continue
+		}
+
+		if cron.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// Parses lists of values, ranges and steps like "1,5-10,*/2"
+func (field cronField) parse(value string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(value, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			parsed, err := strconv.Atoi(part[index+1:])
+			if err != nil || parsed <= 0 {
+				return 0, fmt.Errorf("invalid step in '%v'", part)
+			}
+			step = parsed
+			part = part[:index]
+		}
+
+		start, end := field.min, field.max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			start, err = field.value(bounds[0])
+			if err != nil {
+				return 0, err
+			}
+
+			end = start
+			if len(bounds) == 2 {
+				end, err = field.value(bounds[1])
+				if err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// "5/10" means every tenth value beginning with 5
+				end = field.max
+			}
+
+			if end < start {
+				return 0, fmt.Errorf("invalid range '%v'", part)
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+// The bits of all values from min to max
+func (field cronField) all() uint64 {
+	return (1<<uint(field.max+1) - 1) &^ (1<<uint(field.min) - 1)
+}
+
+func (field cronField) value(value string) (int, error) {
+	for i, name := range field.names {
+		if strings.ToLower(value) == name {
+			return i + field.min, nil
+		}
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < field.min || parsed > field.max {
+		return 0, fmt.Errorf("'%v' must be between %v and %v", value, field.min, field.max)
+	}
+
+	return parsed, nil
+}
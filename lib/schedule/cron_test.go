@@ -0,0 +1,165 @@
+package schedule
+
+import (
+	"start-my-game/lib/config"
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/-1 * * * *",
+		"1-2-3 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"* * * * mon-",
+		"1,,2 * * * *",
+	} {
+		if _, err := ParseCron(expression); err == nil {
+			t.Errorf("the invalid expression %q was accepted", expression)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2020-11-20 is a Friday
+	at := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2020, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	for _, test := range []struct {
+		expression string
+		time       time.Time
+		matches    bool
+	}{
+		{"45 19 * * fri", at(11, 20, 19, 45), true},
+		{"45 19 * * FRI", at(11, 20, 19, 45), true},
+		{"45 19 * * fri", at(11, 21, 19, 45), false},
+		{"45 19 * * fri", at(11, 20, 19, 46), false},
+		{"* * * jan *", at(1, 5, 12, 0), true},
+		{"* * * jan-mar *", at(4, 5, 12, 0), false},
+		{"*/15 0-6 * * *", at(11, 20, 6, 30), true},
+		{"*/15 0-6 * * *", at(11, 20, 6, 31), false},
+		{"*/15 0-6 * * *", at(11, 20, 7, 0), false},
+		{"5/20 * * * *", at(11, 20, 7, 45), true},
+		{"5/20 * * * *", at(11, 20, 7, 40), false},
+		{"0 12 1,15 * *", at(11, 15, 12, 0), true},
+		// Sunday is 0 and 7
+		{"* * * * 0", at(11, 22, 12, 0), true},
+		{"* * * * 7", at(11, 22, 12, 0), true},
+		{"* * * * sun", at(11, 22, 12, 0), true},
+		{"* * * * 7", at(11, 21, 12, 0), false},
+		{"* * * * 5-7", at(11, 22, 12, 0), true},
+		// Either the day of month or the day of week
+		{"0 12 1 * mon", at(11, 1, 12, 0), true},
+		{"0 12 1 * mon", at(11, 2, 12, 0), true},
+		{"0 12 1 * mon", at(11, 3, 12, 0), false},
+		// Both, if one of them covers the full range
+		{"0 12 1 * *", at(11, 2, 12, 0), false},
+		{"0 12 * * mon", at(11, 3, 12, 0), false},
+		{"0 12 1-31 * mon", at(11, 3, 12, 0), false},
+		{"0 12 1 * 0-6", at(11, 2, 12, 0), false},
+		{"0 12 1 * 1-7", at(11, 2, 12, 0), false},
+		{"0 12 1 * */1", at(11, 2, 12, 0), false},
+	} {
+		cron, err := ParseCron(test.expression)
+		if err != nil {
+			t.Errorf("ParseCron(%q) failed: %v", test.expression, err)
+			continue
+		}
+
+		if matches := cron.Matches(test.time); matches != test.matches {
+			t.Errorf("%q matches %v: %v, expected %v", test.expression, test.time, matches, test.matches)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("the time zone data isn't available: %v", err)
+	}
+
+	for _, test := range []struct {
+		expression string
+		after      time.Time
+		next       time.Time
+	}{
+		{"45 19 * * fri", time.Date(2020, 11, 20, 19, 44, 30, 0, berlin), time.Date(2020, 11, 20, 19, 45, 0, 0, berlin)},
+		{"45 19 * * fri", time.Date(2020, 11, 20, 19, 45, 0, 0, berlin), time.Date(2020, 11, 27, 19, 45, 0, 0, berlin)},
+		// Across the month and the year
+		{"0 8 1 * *", time.Date(2020, 11, 30, 9, 0, 0, 0, berlin), time.Date(2020, 12, 1, 8, 0, 0, 0, berlin)},
+		{"45 19 * * fri", time.Date(2020, 12, 26, 0, 0, 0, 0, berlin), time.Date(2021, 1, 1, 19, 45, 0, 0, berlin)},
+		{"0 0 29 feb *", time.Date(2021, 1, 1, 0, 0, 0, 0, berlin), time.Date(2024, 2, 29, 0, 0, 0, 0, berlin)},
+		// Across the end and the start of the daylight saving time
+		{"45 19 * * fri", time.Date(2020, 10, 23, 20, 0, 0, 0, berlin), time.Date(2020, 10, 30, 19, 45, 0, 0, berlin)},
+		{"45 19 * * fri", time.Date(2021, 3, 26, 20, 0, 0, 0, berlin), time.Date(2021, 4, 2, 19, 45, 0, 0, berlin)},
+		{"0 3 * * *", time.Date(2021, 3, 28, 1, 0, 0, 0, berlin), time.Date(2021, 3, 28, 3, 0, 0, 0, berlin)},
+		// Minutes which don't exist because of the change are skipped
+		{"30 2 * * *", time.Date(2021, 3, 28, 1, 0, 0, 0, berlin), time.Date(2021, 3, 29, 2, 30, 0, 0, berlin)},
+		// There's no 31st of February
+		{"0 0 31 2 *", time.Date(2021, 1, 1, 0, 0, 0, 0, berlin), time.Time{}},
+	} {
+		cron, err := ParseCron(test.expression)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) failed: %v", test.expression, err)
+		}
+
+		next := cron.Next(test.after)
+		if !next.Equal(test.next) {
+			t.Errorf("the next time of %q after %v is %v, expected %v", test.expression, test.after, next, test.next)
+		}
+		if !next.IsZero() && next.Location() != berlin {
+			t.Errorf("got the location %v, expected the one of the given time", next.Location())
+		}
+	}
+
+	// The wall clock is kept, the duration between the starts differs because of the change
+	cron, _ := ParseCron("45 19 * * fri")
+	before := cron.Next(time.Date(2020, 10, 22, 0, 0, 0, 0, berlin))
+	after := cron.Next(before)
+	if duration := after.Sub(before); duration != 7*24*time.Hour+time.Hour {
+		t.Errorf("got %v between %v and %v, expected an additional hour", duration, before, after)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	cfg := &config.Config{Schedule: &config.Schedule{
+		TimeZone: "Europe/Berlin",
+		QuietHours: []config.QuietHours{
+			{When: "* 0-7 * * *", RefuseStart: true},
+			{When: "* 22-23 * * fri,sat", ShutdownAfter: 5},
+		},
+	}}
+
+	schedule, err := New(cfg)
+	if err != nil {
+		t.Skipf("the time zone data isn't available: %v", err)
+	}
+
+	// 23:30 UTC is 0:30 in Berlin
+	quiet := schedule.QuietHours(time.Date(2020, 11, 20, 23, 30, 0, 0, time.UTC))
+	if quiet == nil || !quiet.RefuseStart {
+		t.Errorf("got quiet hours %+v, expected the refusing ones", quiet)
+	}
+
+	quiet = schedule.QuietHours(time.Date(2020, 11, 20, 21, 30, 0, 0, time.UTC))
+	if quiet == nil || quiet.RefuseStart || quiet.ShutdownAfter != 5 {
+		t.Errorf("got quiet hours %+v, expected the ones of Friday night", quiet)
+	}
+
+	if quiet := schedule.QuietHours(time.Date(2020, 11, 20, 12, 0, 0, 0, time.UTC)); quiet != nil {
+		t.Errorf("got quiet hours %+v at noon", quiet)
+	}
+}
@@ -0,0 +1,87 @@
+package schedule
+
+import (
+	"fmt"
+	"start-my-game/lib/config"
+	"time"
+)
+
+// The configured times for starts and quiet hours, which is empty if there's no schedule
+type Schedule struct {
+	location   *time.Location
+	prewarm    []*Cron
+	quietHours []quietHours
+}
+
+type quietHours struct {
+	cron   *Cron
+	config config.QuietHours
+}
+
+// Whether the server is started at some point
+func (schedule *Schedule) HasPrewarm() bool {
+	return len(schedule.prewarm) > 0
+}
+
+// The next time after the given one at which the server should be started
+func (schedule *Schedule) NextPrewarm(after time.Time) time.Time {
+	var next time.Time
+
+	for _, cron := range schedule.prewarm {
+		candidate := cron.Next(after.In(schedule.location))
+		if !candidate.IsZero() && (next.IsZero() || candidate.Before(next)) {
+			next = candidate
+		}
+	}
+
+	return next
+}
+
+// Returns the first quiet hours containing the time or nil
+func (schedule *Schedule) QuietHours(t time.Time) *config.QuietHours {
+	local := t.In(schedule.location)
+
+	for _, quiet := range schedule.quietHours {
+		if quiet.cron.Matches(local) {
+			quietCfg := quiet.config
+			return &quietCfg
+		}
+	}
+
+	return nil
+}
+
+func New(cfg *config.Config) (*Schedule, error) {
+	schedule := &Schedule{location: time.Local}
+
+	scheduleCfg := cfg.Schedule
+	if scheduleCfg == nil {
+		return schedule, nil
+	}
+
+	if scheduleCfg.TimeZone != "" {
+		location, err := time.LoadLocation(scheduleCfg.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone '%v': %v", scheduleCfg.TimeZone, err)
+		}
+		schedule.location = location
+	}
+
+	for _, expression := range scheduleCfg.Prewarm {
+		cron, err := ParseCron(expression)
+		if err != nil {
+			return nil, err
+		}
+		schedule.prewarm = append(schedule.prewarm, cron)
+	}
+
+	for _, quietCfg := range scheduleCfg.QuietHours {
+		cron, err := ParseCron(quietCfg.When)
+		if err != nil {
+			return nil, err
+		}
+		schedule.quietHours = append(schedule.quietHours, quietHours{cron: cron, config: quietCfg})
+	}
+
+	return schedule, nil
+}
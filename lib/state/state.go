@@ -0,0 +1,94 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// Stored next to the config file
+const statePath string = "state.json"
+
+// Everything the manager needs to continue after a restart of the application
+type State struct {
+	// Zero if there was no server
+	ServerId         int       `json:"server_id"`
+	LastActivePlayer time.Time `json:"last_active_player"`
+	Lifecycle        string    `json:"lifecycle"`
+	StartupCurrent   int       `json:"startup_current"`
+	StartupMax       int       `json:"startup_max"`
+	// The month as "2006-01" and the seconds a server existed in it until BudgetAccruedAt
+	BudgetMonth     string    `json:"budget_month"`
+	BudgetSeconds   float64   `json:"budget_seconds"`
+	BudgetAccruedAt time.Time `json:"budget_accrued_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type Store struct {
+	path  string
+	mutex sync.Mutex
+	state State
+}
+
+// A copy of the current state
+func (store *Store) Get() State {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.state
+}
+
+// Changes the state using the function and writes it to the disk
+func (store *Store) Update(update func(state *State)) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	update(&store.state)
+	store.state.UpdatedAt = time.Now()
+
+	return store.write()
+}
+
+// The mutex must be locked while calling this method
+func (store *Store) write() error {
+	bytes, err := json.MarshalIndent(store.state, "", "    ")
+	if err != nil {
+		return fmt.Errorf("can't compose state: %v", err)
+	}
+
+	// Writing to a temporary file first, so a crash never leaves a half written state
+	tmpPath := store.path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, bytes, os.FileMode(0640))
+	if err != nil {
+		return fmt.Errorf("can't write state: %v", err)
+	}
+
+	err = os.Rename(tmpPath, store.path)
+	if err != nil {
+		return fmt.Errorf("can't replace state: %v", err)
+	}
+
+	return nil
+}
+
+// Reads the stored state, if there's none an empty state is used
+func Open() (*Store, error) {
+	store := &Store{path: statePath}
+
+	bytes, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("can't load state: %v", err)
+	}
+
+	err = json.Unmarshal(bytes, &store.state)
+	if err != nil {
+		return nil, fmt.Errorf("can't read state: %v", err)
+	}
+
+	return store, nil
+}
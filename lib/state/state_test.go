@@ -0,0 +1,93 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Changes into a temporary directory, because the state is stored in the working directory
+func inTempDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smg-state")
+	if err != nil {
+		t.Fatalf("couldn't create the directory: %v", err)
+	}
+
+	workingDir, _ := os.Getwd()
+	_ = os.Chdir(dir)
+
+	t.Cleanup(func() {
+		_ = os.Chdir(workingDir)
+		_ = os.RemoveAll(dir)
+	})
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	inTempDir(t)
+
+	store, err := Open()
+	if err != nil {
+		t.Fatalf("Open failed without a state file: %v", err)
+	}
+	if store.Get() != (State{}) {
+		t.Errorf("got state %+v without a state file, expected an empty one", store.Get())
+	}
+
+	lastActivePlayer := time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)
+	err = store.Update(func(state *State) {
+		state.ServerId = 42
+		state.LastActivePlayer = lastActivePlayer
+		state.Lifecycle = "running"
+		state.BudgetMonth = "2020-01"
+		state.BudgetSeconds = 3600.5
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if _, err := os.Stat(statePath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("the temporary file wasn't replaced: %v", err)
+	}
+
+	loaded, err := Open()
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	state := loaded.Get()
+	if state.ServerId != 42 || !state.LastActivePlayer.Equal(lastActivePlayer) || state.Lifecycle != "running" ||
+		state.BudgetMonth != "2020-01" || state.BudgetSeconds != 3600.5 {
+		t.Errorf("got state %+v after loading, expected the stored one", state)
+	}
+	if state.UpdatedAt.IsZero() {
+		t.Errorf("the time of the update wasn't stored")
+	}
+
+	// Get returns a copy
+	state.ServerId = 7
+	if loaded.Get().ServerId != 42 {
+		t.Errorf("the state was changed without an update")
+	}
+}
+
+func TestCorruptState(t *testing.T) {
+	inTempDir(t)
+
+	corrupt := []byte(`{"server_id": 42, "lifecycle": "runn`)
+	err := ioutil.WriteFile(statePath, corrupt, 0640)
+	if err != nil {
+		t.Fatalf("couldn't write the state: %v", err)
+	}
+
+	_, err = Open()
+	if err == nil || !strings.Contains(err.Error(), "can't read state") {
+		t.Fatalf("expected an error for the corrupt state, got %v", err)
+	}
+
+	// The file is kept for an inspection
+	if bytes, _ := ioutil.ReadFile(statePath); string(bytes) != string(corrupt) {
+		t.Errorf("the corrupt state was changed to %q", bytes)
+	}
+}
@@ -8,83 +8,160 @@ import (
 	"net/http"
 	"start-my-game/lib/cloud"
 	"start-my-game/lib/config"
+	"start-my-game/lib/game"
 	"start-my-game/lib/manager"
+	"start-my-game/lib/metrics"
 	"strconv"
 	"time"
 )
 
 type ApiServer struct {
 	manger *manager.Manager
+	game   game.Adapter
+	config *config.Config
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
 }
 
 type StartResponse struct {
-	// Can be 'already_running', 'in_startup', 'starting', 'creating' or 'failure'
+	// Can be 'already_running', 'in_startup', 'stopping', 'starting', 'creating', 'quiet_hours',
+	// 'budget_exhausted' or 'failure'
+	Status string `json:"status"`
+}
+
+type StopResponse struct {
+	// Can be 'not_running', 'in_startup', 'busy', 'failure' or 'stopping'
 	Status string `json:"status"`
 }
 
 type StatusResponse struct {
-	// Can be 'active', 'startup', 'startup_error' or 'off'
+	// Can be 'active', 'startup', 'startup_error', 'stopping' or 'off'
 	Status string `json:"status"`
+	// The detailed state of the server lifecycle, e.g. 'waiting_for_game'
+	State      string    `json:"state"`
+	StateSince time.Time `json:"state_since"`
 	// Must be smaller or equal to ProgressMax
-	Progress     int       `json:"progress"`
-	ProgressMax  int       `json:"progress_max"`
-	Ip           string    `json:"ip"`
+	Progress    int    `json:"progress"`
+	ProgressMax int    `json:"progress_max"`
+	Ip          string `json:"ip"`
+	// The address or the link players use to join the game, empty if there's no server
+	ConnectLink  string    `json:"connect_link"`
 	Name         string    `json:"name"`
 	OnlinePlayer int       `json:"online_player"`
 	LastOnline   time.Time `json:"last_online"`
 }
 
-func Start(cfg *config.Config, manager *manager.Manager) {
+func Start(cfg *config.Config, manager *manager.Manager, adapter game.Adapter) {
+	handler, err := newHandler(cfg, manager, adapter)
+	if err != nil {
+		log.Fatalf("couldn't read the web tokens: %v\n", err)
+	}
+
+	log.Printf("Starting web server on port %v\n", cfg.Web.Port)
+	err = http.ListenAndServe(":"+strconv.Itoa(cfg.Web.Port), handler)
+
+	if err != nil {
+		log.Fatalf("couldn't start web server on port %v: %v\n", cfg.Web.Port, err)
+	}
+}
+
+func newHandler(cfg *config.Config, manager *manager.Manager, adapter game.Adapter) (http.Handler, error) {
+	auth, err := newAuthenticator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	api := ApiServer{
 		manger: manager,
+		game:   adapter,
+		config: cfg,
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/start/", api.startHandler)
-	mux.HandleFunc("/status/", api.statusHandler)
+	mux.HandleFunc("/start/", auth.require(roleStarter, api.startHandler))
+	mux.HandleFunc("/stop/", auth.require(roleStarter, api.stopHandler))
+	mux.HandleFunc("/destroy/", auth.require(roleAdmin, api.destroyHandler))
+	mux.HandleFunc("/status/", auth.status(api.statusHandler))
+	mux.HandleFunc("/events/", auth.stream(api.eventsHandler))
+	// The metrics contain the costs and the errors of the operations, so they're never public
+	mux.HandleFunc("/metrics", auth.require(roleViewer, metrics.Handler().ServeHTTP))
+
+	if cfg.Web.Ui != nil && cfg.Web.Ui.Enabled {
+		mux.Handle("/", newUiHandler(cfg))
+	}
 
-	handler := cors.New(cors.Options{
+	return cors.New(cors.Options{
 		AllowedOrigins: []string{cfg.Web.CorsDomain},
-	}).Handler(mux)
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+	}).Handler(mux), nil
+}
 
-	log.Printf("Starting web server on port %v\n", cfg.Web.Port)
-	err := http.ListenAndServe(":"+strconv.Itoa(cfg.Web.Port), handler)
+func (api *ApiServer) startHandler(writer http.ResponseWriter, request *http.Request) {
+	// Only accepting POST requests
+	if request.Method != "POST" {
+		return
+	}
 
-	if err != nil {
-		log.Fatalf("couldn't start web server on port %v: %v\n", cfg.Web.Port, err)
+	from := requester(request)
+	status := api.manger.Start(from)
+	if status == "creating" || status == "starting" {
+		log.Printf("Request which results in a start from %v", from)
 	}
+
+	jsonResponse(writer, StartResponse{Status: status})
 }
 
-func (api *ApiServer) startHandler(writer http.ResponseWriter, request *http.Request) {
+// Shuts down and destroys the server, a snapshot is taken if the parameter snapshot is true or
+// if it's missing and snapshots on shutdown are enabled
+func (api *ApiServer) stopHandler(writer http.ResponseWriter, request *http.Request) {
 	// Only accepting POST requests
 	if request.Method != "POST" {
 		return
 	}
 
-	status := ""
+	snapshot := api.config.Cloud.SnapshotOnShutdown
+	if value := request.URL.Query().Get("snapshot"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			errorResponse(writer, http.StatusBadRequest, "invalid value for snapshot")
+			return
+		}
+		snapshot = parsed
+	}
 
-	if api.manger.Startup != nil && api.manger.Startup.InProgress() {
-		status = "in_startup"
-	} else {
-		api.manger.UpdateActiveServer()
+	status := api.manger.Stop(snapshot)
+	if status == "stopping" {
+		log.Printf("Request which results in a stop (snapshot: %v) from %v", snapshot, requester(request))
+	}
 
-		server := api.manger.ActiveServer
-		if server == nil {
-			log.Printf("Request which results in a start from %v", requestingAddr(request))
-			go api.manger.CreateServer()
-			status = "creating"
-		} else {
-			if server.Status == cloud.StatusActive {
-				status = "already_running"
-			} else {
-				log.Printf("Request which results in a start from %v", requestingAddr(request))
-				go api.manger.StartServer()
-				status = "starting"
-			}
-		}
+	jsonResponse(writer, StopResponse{Status: status})
+}
+
+// Destroys the server immediately without shutting it down
+func (api *ApiServer) destroyHandler(writer http.ResponseWriter, request *http.Request) {
+	// Only accepting POST requests
+	if request.Method != "POST" {
+		return
 	}
 
-	jsonResponse(writer, StartResponse{Status: status})
+	status := api.manger.Destroy()
+	if status == "stopping" {
+		log.Printf("Request which results in a destruction from %v", requester(request))
+	}
+
+	jsonResponse(writer, StopResponse{Status: status})
+}
+
+// The address and the token name of the request for logging
+func requester(request *http.Request) string {
+	user := requestingUser(request)
+	if user == "" {
+		return requestingAddr(request)
+	}
+
+	return fmt.Sprintf("%v by %v", requestingAddr(request), user)
 }
 
 func requestingAddr(request *http.Request) string {
@@ -97,68 +174,54 @@ func requestingAddr(request *http.Request) string {
 }
 
 func (api *ApiServer) statusHandler(writer http.ResponseWriter, request *http.Request) {
-	jsonResponse(writer, generateStatusResponse(api.manger))
+	jsonResponse(writer, api.generateStatusResponse())
 }
 
-func generateStatusResponse(manager *manager.Manager) StatusResponse {
-	server := manager.ActiveServer
-
-	if manager.Startup != nil {
-		// Returning the startup status
-		startup := manager.Startup
-
-		response := StatusResponse{
-			Progress:     startup.Current,
-			ProgressMax:  startup.Max,
-			Ip:           "",
-			Name:         "",
-			OnlinePlayer: 0,
-			LastOnline:   manager.LastActivePlayer,
-		}
-
-		if server != nil {
-			response.Ip = server.Ip
-		}
+func (api *ApiServer) generateStatusResponse() StatusResponse {
+	status := api.manger.Status()
+
+	response := StatusResponse{
+		Status:       status.ServerStatus,
+		State:        string(status.State),
+		StateSince:   status.StateSince,
+		Progress:     0,
+		ProgressMax:  0,
+		Ip:           "",
+		Name:         "",
+		OnlinePlayer: 0,
+		LastOnline:   status.LastActivePlayer,
+	}
 
-		if startup.InProgress() {
-			response.Status = "startup"
-			return response
-		} else if startup.Error {
-			response.Status = "startup_error"
-			return response
-		}
+	if status.Server != nil && status.Server.Ip != "" {
+		response.Ip = status.Server.Ip
+		response.ConnectLink = api.game.ConnectLink(status.Server.Ip)
 	}
 
-	if server != nil {
-		response := StatusResponse{
-			Status:      manager.GetServerStatus(),
-			Progress:    0,
-			ProgressMax: 0,
-			Ip:          server.Ip,
-			LastOnline:  manager.LastActivePlayer,
+	switch response.Status {
+	case cloud.StatusStartup, "startup_error":
+		// Returning the startup progress
+		if status.Startup != nil {
+			response.Progress = status.Startup.Current
+			response.ProgressMax = status.Startup.Max
 		}
-
+	case cloud.StatusActive:
 		// Handle the case if the application just was started
-		if manager.LastRconInfo != nil {
-			response.Name = manager.LastRconInfo.Name
-			response.OnlinePlayer = manager.LastRconInfo.Online
+		if status.LastGameInfo != nil {
+			response.Name = status.LastGameInfo.Name
+			response.OnlinePlayer = status.LastGameInfo.Online
 		} else {
 			response.Name = "Lädt..."
-			response.OnlinePlayer = 0
-		}
-
-		return response
-	} else {
-		return StatusResponse{
-			Status:       manager.GetServerStatus(),
-			Progress:     0,
-			ProgressMax:  0,
-			Name:         "",
-			Ip:           "",
-			OnlinePlayer: 0,
-			LastOnline:   manager.LastActivePlayer,
 		}
 	}
+
+	return response
+}
+
+func errorResponse(writer http.ResponseWriter, status int, message string) {
+	writer.Header().Add("Content-Type", "application/json")
+	writer.WriteHeader(status)
+
+	_ = json.NewEncoder(writer).Encode(ErrorResponse{Error: message})
 }
 
 func jsonResponse(writer http.ResponseWriter, response interface{}) {
@@ -0,0 +1,311 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"start-my-game/lib/cloud"
+	"start-my-game/lib/config"
+	"start-my-game/lib/game"
+	"start-my-game/lib/manager"
+	"start-my-game/lib/schedule"
+	"start-my-game/lib/state"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A game server which is always ready
+type fakeAdapter struct{}
+
+func (adapter *fakeAdapter) GetType() string {
+	return "fake"
+}
+
+func (adapter *fakeAdapter) Probe(ctx context.Context, ip string) error {
+	return nil
+}
+
+func (adapter *fakeAdapter) ServerInfo(ctx context.Context, ip string) (*game.ServerInfo, error) {
+	return &game.ServerInfo{Name: "fake", Online: 1, Max: 16}, nil
+}
+
+func (adapter *fakeAdapter) Command(ctx context.Context, ip string, command string) (string, error) {
+	return "", nil
+}
+
+func (adapter *fakeAdapter) Broadcast(ctx context.Context, ip string, message string) error {
+	return nil
+}
+
+func (adapter *fakeAdapter) Shutdown(ctx context.Context, ip string) error {
+	return nil
+}
+
+func (adapter *fakeAdapter) ConnectLink(ip string) string {
+	return "steam://connect/" + ip
+}
+
+func (adapter *fakeAdapter) Close() error {
+	return nil
+}
+
+var testTokens = []config.Token{
+	{Name: "viewer", Token: "viewer-token", Role: "viewer"},
+	{Name: "starter", Token: "starter-token", Role: "starter"},
+	{Name: "admin", Token: "admin-token", Role: "Admin"},
+}
+
+func newTestConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Cloud.Provider = "fake"
+	cfg.Cloud.ServerName = "smg-test"
+	cfg.Cloud.Snapshot = "gmod"
+	cfg.Cloud.Fake = &config.Fake{Ip: "192.0.2.1"}
+	cfg.Game.Type = "fake"
+	cfg.Game.CheckInterval = 5
+	cfg.Game.ShutdownAfter = 60
+	cfg.Web.CorsDomain = "http://example.com"
+	cfg.Web.Tokens = testTokens
+
+	return cfg
+}
+
+// Serves the API with a manager using the fake provider. The state is stored in a temporary
+// directory. A server is created before, if running is true.
+func newTestApi(t *testing.T, cfg *config.Config, running bool) (*httptest.Server, *manager.Manager, cloud.Cloud) {
+	dir, err := ioutil.TempDir("", "smg-web")
+	if err != nil {
+		t.Fatalf("couldn't create the state directory: %v", err)
+	}
+
+	workingDir, _ := os.Getwd()
+	// The state is stored in the working directory
+	_ = os.Chdir(dir)
+	t.Cleanup(func() {
+		_ = os.Chdir(workingDir)
+		_ = os.RemoveAll(dir)
+	})
+
+	acloud, err := cloud.NewCloud(cfg)
+	if err != nil {
+		t.Fatalf("couldn't create the cloud: %v", err)
+	}
+
+	if running {
+		snapshot, _ := acloud.GetSnapshot(cfg.Cloud.Snapshot)
+		_, err := acloud.CreateServer(cloud.CreateOptions{Name: cfg.Cloud.ServerName, Snapshot: snapshot})
+		if err != nil {
+			t.Fatalf("couldn't create the server: %v", err)
+		}
+	}
+
+	store, err := state.Open()
+	if err != nil {
+		t.Fatalf("couldn't open the state: %v", err)
+	}
+
+	sched, err := schedule.New(cfg)
+	if err != nil {
+		t.Fatalf("couldn't create the schedule: %v", err)
+	}
+
+	newManager := manager.NewManager(cfg, acloud, &fakeAdapter{}, store, sched)
+	handler, err := newHandler(cfg, newManager, &fakeAdapter{})
+	if err != nil {
+		t.Fatalf("couldn't create the handler: %v", err)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server, newManager, acloud
+}
+
+// Sends the request with the token, if it isn't empty
+func request(t *testing.T, method string, url string, token string) (int, string) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("couldn't compose the request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%v %v failed: %v", method, url, err)
+	}
+	defer response.Body.Close()
+
+	body, _ := ioutil.ReadAll(response.Body)
+	return response.StatusCode, string(body)
+}
+
+func TestRoles(t *testing.T) {
+	server, _, _ := newTestApi(t, newTestConfig(), false)
+
+	for _, test := range []struct {
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"GET", "/status/", "", http.StatusUnauthorized},
+		{"GET", "/status/", "unknown", http.StatusUnauthorized},
+		{"GET", "/status/", "viewer-token", http.StatusOK},
+		{"GET", "/metrics", "", http.StatusUnauthorized},
+		{"GET", "/metrics", "viewer-token", http.StatusOK},
+		{"POST", "/start/", "viewer-token", http.StatusForbidden},
+		{"POST", "/stop/", "viewer-token", http.StatusForbidden},
+		{"POST", "/stop/", "starter-token", http.StatusOK},
+		{"POST", "/destroy/", "starter-token", http.StatusForbidden},
+		{"POST", "/destroy/", "admin-token", http.StatusOK},
+		// Preflight requests never contain credentials
+		{"OPTIONS", "/start/", "", http.StatusOK},
+	} {
+		status, body := request(t, test.method, server.URL+test.path, test.token)
+		if status != test.status {
+			t.Errorf("%v %v with token %q returned %v, expected %v: %v",
+				test.method, test.path, test.token, status, test.status, body)
+		}
+	}
+}
+
+func TestQueryToken(t *testing.T) {
+	server, _, _ := newTestApi(t, newTestConfig(), false)
+
+	// Tokens in URLs end up in logs, so only the event stream accepts them
+	status, _ := request(t, "GET", server.URL+"/status/?access_token=viewer-token", "")
+	if status != http.StatusUnauthorized {
+		t.Errorf("the status returned %v for a token in the query, expected %v", status, http.StatusUnauthorized)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events/?access_token=viewer-token", nil)
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("requesting the events failed: %v", err)
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("the events returned %v for a token in the query, expected %v", response.StatusCode, http.StatusOK)
+	}
+}
+
+func TestPublicStatus(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Web.PublicStatus = true
+	server, _, _ := newTestApi(t, cfg, false)
+
+	if status, body := request(t, "GET", server.URL+"/status/", ""); status != http.StatusOK {
+		t.Errorf("the public status returned %v: %v", status, body)
+	}
+
+	if status, _ := request(t, "GET", server.URL+"/metrics", ""); status != http.StatusUnauthorized {
+		t.Errorf("the metrics returned %v without a token, expected %v", status, http.StatusUnauthorized)
+	}
+
+	if status, _ := request(t, "POST", server.URL+"/start/", ""); status != http.StatusUnauthorized {
+		t.Errorf("starting returned %v without a token, expected %v", status, http.StatusUnauthorized)
+	}
+
+	// A wrong token isn't treated like a missing one for the other routes
+	if status, _ := request(t, "POST", server.URL+"/start/", "viewer-token"); status != http.StatusForbidden {
+		t.Errorf("starting returned %v for a viewer, expected %v", status, http.StatusForbidden)
+	}
+}
+
+func TestPlaceholderToken(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Web.Tokens = []config.Token{{Name: "YourName", Token: config.PlaceholderToken, Role: "admin"}}
+
+	if _, err := newHandler(cfg, nil, nil); err == nil {
+		t.Errorf("the placeholder token was accepted")
+	}
+
+	cfg.Web.Tokens = nil
+	if _, err := newHandler(cfg, nil, nil); err == nil {
+		t.Errorf("the API was served without a token")
+	}
+}
+
+func TestStopAndDestroy(t *testing.T) {
+	server, _, acloud := newTestApi(t, newTestConfig(), true)
+
+	// Only POST requests are accepted
+	if status, body := request(t, "GET", server.URL+"/destroy/", "admin-token"); status != http.StatusOK || body != "" {
+		t.Errorf("a GET request returned %v: %v", status, body)
+	}
+
+	if status, _ := request(t, "POST", server.URL+"/stop/?snapshot=maybe", "starter-token"); status != http.StatusBadRequest {
+		t.Errorf("an invalid snapshot parameter returned %v, expected %v", status, http.StatusBadRequest)
+	}
+
+	var response StopResponse
+	_, body := request(t, "POST", server.URL+"/destroy/", "admin-token")
+	if err := json.Unmarshal([]byte(body), &response); err != nil || response.Status != "stopping" {
+		t.Fatalf("destroying returned %v (%v), expected stopping", body, err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := acloud.GetServer("smg-test"); cloud.IsNotExistsError(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the server wasn't destroyed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Waiting until the destruction is finished
+	for {
+		_, body = request(t, "POST", server.URL+"/stop/", "starter-token")
+		if strings.Contains(body, "not_running") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stopping returned %v, expected not_running after the destruction", body)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStatusConnectLink(t *testing.T) {
+	server, _, _ := newTestApi(t, newTestConfig(), true)
+
+	var response StatusResponse
+	_, body := request(t, "GET", server.URL+"/status/", "viewer-token")
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatalf("couldn't read the status %q: %v", body, err)
+	}
+
+	// The link is composed by the game adapter
+	if response.Ip != "192.0.2.1" || response.ConnectLink != "steam://connect/192.0.2.1" {
+		t.Errorf("got the address %q and the link %q", response.Ip, response.ConnectLink)
+	}
+}
+
+func TestStartInQuietHours(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Schedule = &config.Schedule{QuietHours: []config.QuietHours{{When: "* * * * *", RefuseStart: true}}}
+	server, _, acloud := newTestApi(t, cfg, false)
+
+	_, body := request(t, "POST", server.URL+"/start/", "starter-token")
+
+	var response StartResponse
+	if err := json.Unmarshal([]byte(body), &response); err != nil || response.Status != "quiet_hours" {
+		t.Errorf("starting returned %v (%v), expected quiet_hours", body, err)
+	}
+
+	if _, err := acloud.GetServer("smg-test"); !cloud.IsNotExistsError(err) {
+		t.Errorf("a server was created during the quiet hours: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"start-my-game/lib/config"
+	"strings"
+)
+
+// Every role includes the permissions of the roles before
+const (
+	roleViewer  = "viewer"
+	roleStarter = "starter"
+	roleAdmin   = "admin"
+)
+
+var roleLevels = map[string]int{
+	roleViewer:  1,
+	roleStarter: 2,
+	roleAdmin:   3,
+}
+
+type contextKey string
+
+const userKey contextKey = "user"
+
+type Authenticator struct {
+	tokens       []config.Token
+	publicStatus bool
+}
+
+// Only passes requests to the handler, which have a token with at least the role
+func (auth *Authenticator) require(role string, handler http.HandlerFunc) http.HandlerFunc {
+	return auth.guard(role, false, false, handler)
+}
+
+// Like require with the viewer role, but requests without a token are passed if the status is public
+func (auth *Authenticator) status(handler http.HandlerFunc) http.HandlerFunc {
+	return auth.guard(roleViewer, auth.publicStatus, false, handler)
+}
+
+// Like status, but the token can also be passed as query parameter access_token, because browsers
+// can't set headers for Server-Sent Events. Tokens in URLs end up in logs, so no other route accepts it.
+func (auth *Authenticator) stream(handler http.HandlerFunc) http.HandlerFunc {
+	return auth.guard(roleViewer, auth.publicStatus, true, handler)
+}
+
+func (auth *Authenticator) guard(role string, public bool, query bool, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		// Preflight requests never contain credentials
+		if request.Method == http.MethodOptions {
+			handler(writer, request)
+			return
+		}
+
+		token := auth.find(request, query)
+		if token == nil {
+			if public {
+				handler(writer, request)
+				return
+			}
+
+			writer.Header().Set("WWW-Authenticate", `Bearer realm="StartMyGame"`)
+			errorResponse(writer, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+
+		if roleLevels[strings.ToLower(token.Role)] < roleLevels[role] {
+			errorResponse(writer, http.StatusForbidden, fmt.Sprintf("the role %v is required", role))
+			return
+		}
+
+		ctx := context.WithValue(request.Context(), userKey, token.Name)
+		handler(writer, request.WithContext(ctx))
+	}
+}
+
+func (auth *Authenticator) find(request *http.Request, query bool) *config.Token {
+	var given []byte
+
+	header := request.Header.Get("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		given = []byte(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+	} else if token := request.URL.Query().Get("access_token"); query && token != "" {
+		given = []byte(token)
+	} else {
+		return nil
+	}
+
+	for i, token := range auth.tokens {
+		if subtle.ConstantTimeCompare(given, []byte(token.Token)) == 1 {
+			return &auth.tokens[i]
+		}
+	}
+
+	return nil
+}
+
+// The name of the token used for the request or an empty string
+func requestingUser(request *http.Request) string {
+	name, _ := request.Context().Value(userKey).(string)
+	return name
+}
+
+func newAuthenticator(cfg *config.Config) (*Authenticator, error) {
+	// The API would be open to everyone otherwise
+	if len(cfg.Web.Tokens) == 0 {
+		return nil, fmt.Errorf("at least one token is required")
+	}
+
+	for _, token := range cfg.Web.Tokens {
+		if _, ok := roleLevels[strings.ToLower(token.Role)]; !ok {
+			return nil, fmt.Errorf("token '%v' has the unknown role '%v'", token.Name, token.Role)
+		}
+
+		if token.Token == "" {
+			return nil, fmt.Errorf("token '%v' is empty", token.Name)
+		}
+
+		// Everyone knows the token of the default config
+		if token.Token == config.PlaceholderToken {
+			return nil, fmt.Errorf("replace the placeholder token of '%v'", token.Name)
+		}
+	}
+
+	return &Authenticator{
+		tokens:       cfg.Web.Tokens,
+		publicStatus: cfg.Web.PublicStatus,
+	}, nil
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"start-my-game/lib/manager"
+	"strconv"
+	"time"
+)
+
+// Comments are sent regularly, so proxies don't close idle connections
+const heartbeatInterval = 15 * time.Second
+
+// Browsers reconnect after this many milliseconds
+const reconnectDelay = 3000
+
+type TransitionEvent struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+}
+
+// Streams a StatusResponse whenever the state of the manager changes and all lifecycle transitions
+// as Server-Sent Events. Reconnecting clients receive the transitions they missed.
+func (api *ApiServer) eventsHandler(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		errorResponse(writer, http.StatusInternalServerError, "streaming isn't supported")
+		return
+	}
+
+	lastId := 0
+	if value := request.Header.Get("Last-Event-ID"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			errorResponse(writer, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		lastId = parsed
+	}
+
+	events, missed, unsubscribe := api.manger.Subscribe(lastId)
+	defer unsubscribe()
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.WriteHeader(http.StatusOK)
+
+	_, _ = fmt.Fprintf(writer, "retry: %v\n\n", reconnectDelay)
+
+	for _, event := range missed {
+		if event.Type == manager.EventTransition {
+			if !api.writeEvent(writer, event) {
+				return
+			}
+		}
+	}
+
+	// The current status replaces all missed status events
+	current := manager.Event{Id: api.manger.LastEventId(), Type: manager.EventStatus}
+	if !api.writeEvent(writer, current) {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(writer, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok || !api.writeEvent(writer, event) {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+// Returns false if the client can't be reached anymore
+func (api *ApiServer) writeEvent(writer http.ResponseWriter, event manager.Event) bool {
+	var data interface{}
+
+	switch event.Type {
+	case manager.EventStatus:
+		data = api.generateStatusResponse()
+	case manager.EventTransition:
+		data = TransitionEvent{
+			From:   string(event.Transition.From),
+			To:     string(event.Transition.To),
+			Time:   event.Transition.Time,
+			Reason: event.Transition.Reason,
+		}
+	default:
+		return true
+	}
+
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		log.Println("Couldn't compose event:", err)
+		return true
+	}
+
+	_, err = fmt.Fprintf(writer, "id: %v\nevent: %v\ndata: %s\n\n", event.Id, event.Type, bytes)
+	return err == nil
+}
@@ -0,0 +1,138 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"start-my-game/lib/manager"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testEvent struct {
+	id   int
+	kind string
+	data string
+}
+
+// Reads the Server-Sent Events until the first status event
+func readEvents(t *testing.T, scanner *bufio.Scanner) []testEvent {
+	var events []testEvent
+	var event testEvent
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			event.id, _ = strconv.Atoi(strings.TrimPrefix(line, "id: "))
+		case strings.HasPrefix(line, "event: "):
+			event.kind = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && event.kind != "":
+			events = append(events, event)
+			if event.kind == string(manager.EventStatus) {
+				return events
+			}
+			event = testEvent{}
+		}
+	}
+
+	t.Fatalf("the stream ended before a status event: %v", scanner.Err())
+	return nil
+}
+
+// Opens the event stream, which is closed by calling the returned function
+func openEvents(t *testing.T, url string, lastId int) (*bufio.Scanner, func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+
+	request, _ := http.NewRequestWithContext(ctx, "GET", url+"/events/", nil)
+	request.Header.Set("Authorization", "Bearer viewer-token")
+	if lastId > 0 {
+		request.Header.Set("Last-Event-ID", strconv.Itoa(lastId))
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		cancel()
+		t.Fatalf("requesting the events failed: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		cancel()
+		t.Fatalf("the events returned %v", response.StatusCode)
+	}
+
+	return bufio.NewScanner(response.Body), func() {
+		cancel()
+		_ = response.Body.Close()
+	}
+}
+
+// Waits until the handlers of all closed streams stopped their subscription
+func waitForSubscribers(t *testing.T, manager *manager.Manager, expected int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for manager.Subscribers() != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %v subscribers, expected %v", manager.Subscribers(), expected)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEventsReconnect(t *testing.T) {
+	server, newManager, _ := newTestApi(t, newTestConfig(), true)
+
+	scanner, closeEvents := openEvents(t, server.URL, 0)
+	first := readEvents(t, scanner)
+	waitForSubscribers(t, newManager, 1)
+
+	lastId := first[len(first)-1].id
+	closeEvents()
+	waitForSubscribers(t, newManager, 0)
+
+	// Destroying the server switches to destroying and off while nobody is connected
+	if status, body := request(t, "POST", server.URL+"/destroy/", "admin-token"); !strings.Contains(body, "stopping") {
+		t.Fatalf("destroying returned %v: %v", status, body)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for newManager.Lifecycle().State() != manager.StateOff {
+		if time.Now().After(deadline) {
+			t.Fatalf("the server wasn't destroyed, the lifecycle is in state %v", newManager.Lifecycle().State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	scanner, closeEvents = openEvents(t, server.URL, lastId)
+	defer closeEvents()
+	missed := readEvents(t, scanner)
+
+	var states []string
+	for _, event := range missed {
+		if event.id <= lastId {
+			t.Errorf("got event %v, which was received before the reconnect at %v", event.id, lastId)
+		}
+
+		if event.kind == string(manager.EventTransition) {
+			var transition TransitionEvent
+			if err := json.Unmarshal([]byte(event.data), &transition); err != nil {
+				t.Fatalf("couldn't read the transition %q: %v", event.data, err)
+			}
+			states = append(states, transition.From+"->"+transition.To)
+		}
+	}
+
+	if strings.Join(states, " ") != "running->destroying destroying->off" {
+		t.Errorf("got the transitions %v after the reconnect", states)
+	}
+
+	var status StatusResponse
+	if err := json.Unmarshal([]byte(missed[len(missed)-1].data), &status); err != nil || status.Status != "off" {
+		t.Errorf("got the status %v (%v), expected off", missed[len(missed)-1].data, err)
+	}
+}
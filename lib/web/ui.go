@@ -0,0 +1,270 @@
+package web
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"start-my-game/lib/config"
+	"strconv"
+)
+
+const defaultUiLanguage = "en"
+
+// The texts of the web interface by language
+var uiTexts = map[string]map[string]string{
+	"en": {
+		"start":            "Start server",
+		"token":            "Access token",
+		"save":             "Save",
+		"address":          "Address",
+		"connect":          "Connect",
+		"players":          "Players online",
+		"last_online":      "Last player online",
+		"unauthorized":     "A valid access token is required",
+		"forbidden":        "Your access token isn't allowed to do this",
+		"failure":          "The request failed",
+		"off":              "The server is offline",
+		"startup":          "The server is starting...",
+		"startup_error":    "The server couldn't be started",
+		"active":           "The server is online",
+		"stopping":         "The server is shutting down",
+		"already_running":  "The server is already running",
+		"in_startup":       "The server is already starting",
+		"starting":         "The server will be started",
+		"creating":         "The server will be created",
+		"quiet_hours":      "The server can't be started during the quiet hours",
+		"budget_exhausted": "The budget for this month is exhausted",
+	},
+	"de": {
+		"start":            "Server starten",
+		"token":            "Zugangstoken",
+		"save":             "Speichern",
+		"address":          "Adresse",
+		"connect":          "Verbinden",
+		"players":          "Spieler online",
+		"last_online":      "Zuletzt Spieler online",
+		"unauthorized":     "Ein gültiges Zugangstoken wird benötigt",
+		"forbidden":        "Dein Zugangstoken darf das nicht",
+		"failure":          "Die Anfrage ist fehlgeschlagen",
+		"off":              "Der Server ist offline",
+		"startup":          "Der Server startet...",
+		"startup_error":    "Der Server konnte nicht gestartet werden",
+		"active":           "Der Server ist online",
+		"stopping":         "Der Server wird heruntergefahren",
+		"already_running":  "Der Server läuft bereits",
+		"in_startup":       "Der Server startet bereits",
+		"starting":         "Der Server wird gestartet",
+		"creating":         "Der Server wird erstellt",
+		"quiet_hours":      "Der Server kann während der Ruhezeit nicht gestartet werden",
+		"budget_exhausted": "Das Budget für diesen Monat ist aufgebraucht",
+	},
+}
+
+type uiData struct {
+	Title    string
+	Language string
+	Texts    map[string]string
+	// Appended to the IP of the server, empty if the default port of the game is used
+	Port string
+}
+
+var uiTemplate = template.Must(template.New("ui").Parse(uiHtml))
+
+type uiHandler struct {
+	data uiData
+}
+
+func newUiHandler(cfg *config.Config) *uiHandler {
+	language := cfg.Web.Ui.Language
+	if _, ok := uiTexts[language]; !ok {
+		if language != "" {
+			log.Printf("The web interface doesn't support the language '%v', using %v\n", language, defaultUiLanguage)
+		}
+		language = defaultUiLanguage
+	}
+
+	title := cfg.Web.Ui.Title
+	if title == "" {
+		title = cfg.Cloud.ServerName
+	}
+
+	port := ""
+	if cfg.Game.Port != 0 {
+		port = ":" + strconv.Itoa(cfg.Game.Port)
+	}
+
+	return &uiHandler{data: uiData{
+		Title:    title,
+		Language: language,
+		Texts:    uiTexts[language],
+		Port:     port,
+	}}
+}
+
+func (ui *uiHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	// The pattern / of the mux matches every path without another handler
+	if request.URL.Path != "/" {
+		http.NotFound(writer, request)
+		return
+	}
+
+	if request.Method != "GET" && request.Method != "HEAD" {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := uiTemplate.Execute(writer, ui.data)
+	if err != nil {
+		log.Println("Couldn't render the web interface:", err)
+	}
+}
+
+const uiHtml = `<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; background: #f2f2f2; color: #222; margin: 0; }
+main { max-width: 32em; margin: 3em auto; padding: 1.5em; background: #fff; border-radius: 6px; }
+h1 { margin-top: 0; }
+button { font-size: 1.1em; padding: 0.5em 1.2em; cursor: pointer; }
+progress { width: 100%; height: 1.2em; }
+dl { display: grid; grid-template-columns: auto 1fr; gap: 0.4em 1em; }
+dt { font-weight: bold; }
+dd { margin: 0; }
+.hidden { display: none; }
+#message { min-height: 1.2em; color: #a33; }
+footer { margin-top: 2em; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<p id="status"></p>
+<progress id="progress" class="hidden" value="0" max="1"></progress>
+<dl id="details" class="hidden">
+<dt>{{.Texts.address}}</dt><dd><span id="address"></span> <a id="connect" class="hidden">{{.Texts.connect}}</a></dd>
+<dt>{{.Texts.players}}</dt><dd id="players"></dd>
+</dl>
+<dl>
+<dt>{{.Texts.last_online}}</dt><dd id="last-online">-</dd>
+</dl>
+<button id="start">{{.Texts.start}}</button>
+<p id="message"></p>
+<footer>
+<form id="token-form">
+<label for="token">{{.Texts.token}}</label>
+<input id="token" type="password" autocomplete="current-password">
+<button type="submit">{{.Texts.save}}</button>
+</form>
+</footer>
+</main>
+<script>
+(function () {
+  var texts = {{.Texts}};
+  var language = {{.Language}};
+  var port = {{.Port}};
+  var tokenKey = "smg_token";
+  var source = null;
+  var polling = null;
+
+  function byId(id) { return document.getElementById(id); }
+  function token() { return localStorage.getItem(tokenKey) || ""; }
+  function show(id, visible) { byId(id).classList.toggle("hidden", !visible); }
+
+  function headers() {
+    var result = {};
+    if (token()) { result["Authorization"] = "Bearer " + token(); }
+    return result;
+  }
+
+  function failure(response) {
+    if (response.status === 401) { return texts.unauthorized; }
+    if (response.status === 403) { return texts.forbidden; }
+    return texts.failure;
+  }
+
+  function render(status) {
+    byId("status").textContent = texts[status.status] || status.status;
+
+    var inStartup = status.status === "startup" || status.status === "startup_error";
+    show("progress", inStartup && status.progress_max > 0);
+    byId("progress").max = Math.max(status.progress_max, 1);
+    byId("progress").value = status.progress;
+
+    show("details", status.status === "active" && status.ip !== "");
+    byId("address").textContent = status.ip + port;
+    // Games without a link only return the address
+    byId("connect").href = status.connect_link;
+    show("connect", status.connect_link.indexOf("://") > 0);
+    byId("players").textContent = status.online_player;
+
+    var lastOnline = new Date(status.last_online);
+    byId("last-online").textContent = lastOnline.getFullYear() > 1 ? lastOnline.toLocaleString(language) : "-";
+
+    byId("start").disabled = status.status !== "off" && status.status !== "startup_error";
+  }
+
+  function poll() {
+    fetch("status/", {headers: headers()}).then(function (response) {
+      if (!response.ok) { throw failure(response); }
+      return response.json();
+    }).then(function (status) {
+      byId("message").textContent = "";
+      render(status);
+    }).catch(function (message) {
+      byId("message").textContent = typeof message === "string" ? message : texts.failure;
+    });
+  }
+
+  function listen() {
+    if (source) { source.close(); }
+    if (polling) { clearInterval(polling); polling = null; }
+
+    if (!window.EventSource) {
+      poll();
+      polling = setInterval(poll, 5000);
+      return;
+    }
+
+    var query = token() ? "?access_token=" + encodeURIComponent(token()) : "";
+    source = new EventSource("events/" + query);
+    source.addEventListener("status", function (event) {
+      byId("message").textContent = "";
+      render(JSON.parse(event.data));
+    });
+    source.onerror = function () {
+      // Shows the reason, the browser reconnects on its own if the stream wasn't rejected
+      if (source.readyState === EventSource.CLOSED) { poll(); }
+    };
+  }
+
+  byId("start").addEventListener("click", function () {
+    byId("start").disabled = true;
+    fetch("start/", {method: "POST", headers: headers()}).then(function (response) {
+      if (!response.ok) { throw failure(response); }
+      return response.json();
+    }).then(function (response) {
+      byId("message").textContent = texts[response.status] || response.status;
+    }).catch(function (message) {
+      byId("start").disabled = false;
+      byId("message").textContent = typeof message === "string" ? message : texts.failure;
+    });
+  });
+
+  byId("token-form").addEventListener("submit", function (event) {
+    event.preventDefault();
+    localStorage.setItem(tokenKey, byId("token").value);
+    listen();
+  });
+
+  byId("token").value = token();
+  listen();
+})();
+</script>
+</body>
+</html>
+`